@@ -3,7 +3,9 @@ package main
 import (
 	_ "github.com/shalb/cluster.dev/pkg/backend/azurerm"
 	_ "github.com/shalb/cluster.dev/pkg/backend/gcs"
+	_ "github.com/shalb/cluster.dev/pkg/backend/http"
 	_ "github.com/shalb/cluster.dev/pkg/backend/local"
+	_ "github.com/shalb/cluster.dev/pkg/backend/memory"
 	_ "github.com/shalb/cluster.dev/pkg/backend/s3"
 	_ "github.com/shalb/cluster.dev/pkg/logging"
 	_ "github.com/shalb/cluster.dev/pkg/project"
@@ -4,11 +4,19 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
-  "github.com/apex/log"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+	"github.com/apex/log"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/shalb/cluster.dev/pkg/hcltools"
 	"github.com/shalb/cluster.dev/pkg/project"
@@ -20,6 +28,7 @@ import (
 // Backend - describe azure backend for interface package.backend.
 type Backend struct {
 	client                        *azblob.Client
+	leaseID                       string
 	name                          string                 `yaml:"-"`
 	state                         map[string]interface{} `yaml:"-"`
 	ProjectPtr                    *project.Project       `yaml:"-"`
@@ -106,7 +115,7 @@ func (b *Backend) GetBackendHCL(stackName, unitName string) (*hclwrite.File, err
 	if err != nil {
 		return nil, err
 	}
-	bConfigTmpl["key"] = fmt.Sprintf("%s-%s.state", stackName, unitName)
+	bConfigTmpl["key"] = fmt.Sprintf("%s%s-%s.state", b.envPrefix(), stackName, unitName)
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 	terraformBlock := rootBody.AppendNewBlock("terraform", []string{})
@@ -124,7 +133,7 @@ func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error)
 	if err != nil {
 		return nil, err
 	}
-	bConfigTmpl["key"] = fmt.Sprintf("%s-%s.state", stackName, unitName)
+	bConfigTmpl["key"] = fmt.Sprintf("%s%s-%s.state", b.envPrefix(), stackName, unitName)
 	f := hclwrite.NewEmptyFile()
 
 	rootBody := f.Body()
@@ -139,78 +148,211 @@ func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error)
 	return f.Bytes(), nil
 }
 
-func (b *Backend) LockState() error {
-	lockKey := fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name())
+func (b *Backend) lockBlobClient() *blockblob.Client {
+	lockKey := fmt.Sprintf("%scdev.%s.lock", b.envPrefix(), b.ProjectPtr.Name())
+	return b.client.ServiceClient().NewContainerClient(b.ContainerName).NewBlockBlobClient(lockKey)
+}
+
+func (b *Backend) LockState(lockInfo string) error {
+	log.Debugf("Locking azurerm state. Project: '%v', container: '%v'", b.ProjectPtr.Name(), b.ContainerName)
 	ctx := context.Background()
+	lockBlob := b.lockBlobClient()
 
-	// Check if the blob exists.
-	_, err := b.client.DownloadStream(ctx, b.ContainerName, lockKey, nil)
-	if err == nil {
-		return fmt.Errorf("Lock state blob found, the state is locked")
+	// Create the lock blob if it does not exist. The lock itself is the blob lease.
+	_, err := lockBlob.Upload(ctx, streaming.NopCloser(bytes.NewReader([]byte{})), &blockblob.UploadOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		},
+	})
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet, bloberror.LeaseIDMissing) {
+		return fmt.Errorf("lock state: can't create lock blob: %w", err)
 	}
 
-	sessionID := utils.RandString(10)
-
-	// Upload a blob to Azure Blob Storage.
-	buf := []byte(sessionID)
-	_, err = b.client.UploadBuffer(ctx, b.ContainerName, lockKey, buf, &azblob.UploadBufferOptions{})
+	// Acquire the infinite lease, only one of the concurrent lockers succeeds.
+	leaseClient, err := lease.NewBlobClient(lockBlob, nil)
+	if err != nil {
+		return fmt.Errorf("lock state: %w", err)
+	}
+	_, err = leaseClient.AcquireLease(ctx, -1, nil)
 	if err != nil {
-		return fmt.Errorf("Can't save lock state blob: %v", err)
+		if bloberror.HasCode(err, bloberror.LeaseAlreadyPresent, bloberror.BlobNotFound) {
+			return fmt.Errorf("lock state: %w, lock blob '%v' is leased. Use command 'cdev state unlock' to force unlock (unsafe)", project.ErrStateLocked, lockBlob.URL())
+		}
+		return fmt.Errorf("lock state: can't acquire lock blob lease: %w", err)
 	}
+	b.leaseID = *leaseClient.LeaseID()
 
+	// Save the lock info to the lock blob.
+	_, err = lockBlob.Upload(ctx, streaming.NopCloser(strings.NewReader(lockInfo)), &blockblob.UploadOptions{
+		AccessConditions: b.leaseAccessConditions(),
+	})
+	if err != nil {
+		// Release the lease, the state would stay locked until force unlock otherwise.
+		if _, relErr := leaseClient.ReleaseLease(ctx, nil); relErr != nil {
+			log.Warnf("Lock state: can't release lock blob lease: %v", relErr)
+		}
+		b.leaseID = ""
+		return fmt.Errorf("lock state: can't save lock blob: %w", err)
+	}
 	return nil
 }
 
-func (b *Backend) UnlockState() error {
-	lockKey := fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name())
+func (b *Backend) leaseAccessConditions() *blob.AccessConditions {
+	return &blob.AccessConditions{
+		LeaseAccessConditions: &blob.LeaseAccessConditions{LeaseID: to.Ptr(b.leaseID)},
+	}
+}
+
+func (b *Backend) ReadLock() (string, bool, error) {
 	ctx := context.Background()
-	_, err := b.client.DeleteBlob(ctx, b.ContainerName, lockKey, nil)
+	get, err := b.lockBlobClient().DownloadStream(ctx, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("read lock blob: %w", err)
+	}
+	defer get.Body.Close()
+	// The lock blob without lease is left by an old cdev version or a crashed locker, it does not lock the state.
+	if get.LeaseState == nil || *get.LeaseState != lease.StateTypeLeased {
+		return "", false, nil
+	}
+	lockInfo, err := io.ReadAll(get.Body)
 	if err != nil {
-		return fmt.Errorf("Can't unlock state: %v", err)
+		return "", false, fmt.Errorf("read lock blob: %w", err)
 	}
+	return string(lockInfo), true, nil
+}
+
+func (b *Backend) UnlockState() error {
+	log.Debugf("Unlocking azurerm state. Project: '%v', container: '%v'", b.ProjectPtr.Name(), b.ContainerName)
+	ctx := context.Background()
+	lockBlob := b.lockBlobClient()
 
+	deleteOptions := &blob.DeleteOptions{}
+	if b.leaseID != "" {
+		// The lease was acquired by this process, delete the lock blob with it.
+		deleteOptions.AccessConditions = b.leaseAccessConditions()
+	} else {
+		// Force unlock: break the lease of another process immediately.
+		leaseClient, err := lease.NewBlobClient(lockBlob, nil)
+		if err != nil {
+			return fmt.Errorf("unlock state: %w", err)
+		}
+		_, err = leaseClient.BreakLease(ctx, &lease.BlobBreakOptions{BreakPeriod: to.Ptr(int32(0))})
+		if err != nil && !bloberror.HasCode(err, bloberror.LeaseNotPresentWithLeaseOperation, bloberror.BlobNotFound) {
+			return fmt.Errorf("unlock state: can't break lock blob lease: %w", err)
+		}
+	}
+	_, err := lockBlob.Delete(ctx, deleteOptions)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("unlock state: %w", err)
+	}
+	b.leaseID = ""
 	return nil
 }
 
-func (b *Backend) WriteState(stateData string) error {
-	stateKey := fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name())
+// readBlob downloads the blob. Returns false if the blob does not exist.
+func (b *Backend) readBlob(key string) (string, bool, error) {
 	ctx := context.Background()
-	buf := []byte(stateData)
-	_, err := b.client.UploadBuffer(ctx, b.ContainerName, stateKey, buf, &azblob.UploadBufferOptions{})
+	get, err := b.client.DownloadStream(ctx, b.ContainerName, key, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			log.Debugf("The blob '%v' does not exist.", key)
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	data := bytes.Buffer{}
+	retryReader := get.NewRetryReader(ctx, &azblob.RetryReaderOptions{})
+	defer retryReader.Close()
+	_, err = data.ReadFrom(retryReader)
 	if err != nil {
-		return fmt.Errorf("Can't save state blob: %v", err)
+		return "", false, err
 	}
+	return data.String(), true, nil
+}
+
+func (b *Backend) writeBlob(key, data string) error {
+	_, err := b.client.UploadBuffer(context.Background(), b.ContainerName, key, []byte(data), &azblob.UploadBufferOptions{})
+	return err
+}
 
+func (b *Backend) WriteState(stateData string) error {
+	err := b.writeBlob(b.stateKey(), stateData)
+	if err != nil {
+		return fmt.Errorf("can't save state blob: %w", err)
+	}
 	return nil
 }
 
 func (b *Backend) ReadState() (string, error) {
-	stateKey := fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name())
-	ctx := context.Background()
+	stateData, _, err := b.readBlob(b.stateKey())
+	if err != nil {
+		return "", fmt.Errorf("can't read state blob: %w", err)
+	}
+	return stateData, nil
+}
 
-	// Check if the object exists.
-	_, err := b.client.DownloadStream(ctx, b.ContainerName, stateKey, nil)
+func (b *Backend) ReadStateHistory() (string, error) {
+	history, _, err := b.readBlob(b.historyKey())
 	if err != nil {
-		// Check if the error message contains "BlobNotFound" to identify the error.
-		if strings.Contains(err.Error(), "BlobNotFound") {
-			log.Debugf("The blob does not exist.")
-			return "", nil
-		}
-		return "", err
+		return "", fmt.Errorf("can't read state history blob: %w", err)
 	}
+	return history, nil
+}
 
-	// Download the blob
-	get, err := b.client.DownloadStream(ctx, b.ContainerName, stateKey, nil)
+func (b *Backend) WriteStateHistory(history string) error {
+	err := b.writeBlob(b.historyKey(), history)
 	if err != nil {
-		fmt.Errorf("Can't read state blob: %v", err)
-		return "", err
+		return fmt.Errorf("can't save state history blob: %w", err)
 	}
+	return nil
+}
 
-	stateData := bytes.Buffer{}
-	retryReader := get.NewRetryReader(ctx, &azblob.RetryReaderOptions{})
-	_, err = stateData.ReadFrom(retryReader)
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	stateData, exists, err := b.readBlob(b.stateVersionKey(version))
+	if err != nil {
+		return "", fmt.Errorf("can't read state version %v blob: %w", version, err)
+	}
+	if !exists {
+		return "", fmt.Errorf("can't read state version %v blob: %w", version, project.ErrStateVersionNotFound)
+	}
+	return stateData, nil
+}
+
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	err := b.writeBlob(b.stateVersionKey(version), stateData)
+	if err != nil {
+		return fmt.Errorf("can't save state version %v blob: %w", version, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	_, err := b.client.DeleteBlob(context.Background(), b.ContainerName, b.stateVersionKey(version), nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("can't delete state version %v blob: %w", version, err)
+	}
+	return nil
+}
+
+// envPrefix returns the blob name prefix of the active environment states.
+func (b *Backend) envPrefix() string {
+	if b.ProjectPtr.Env() == "" {
+		return ""
+	}
+	return b.ProjectPtr.Env() + "/"
+}
+
+func (b *Backend) stateKey() string {
+	return fmt.Sprintf("%scdev.%s.state", b.envPrefix(), b.ProjectPtr.Name())
+}
 
-	err = retryReader.Close()
+func (b *Backend) stateVersionKey(version int) string {
+	return fmt.Sprintf("%s.%d", b.stateKey(), version)
+}
 
-	return stateData.String(), nil
+func (b *Backend) historyKey() string {
+	return fmt.Sprintf("%scdev.%s.history", b.envPrefix(), b.ProjectPtr.Name())
 }
@@ -0,0 +1,187 @@
+package azurerm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+type fakeBlob struct {
+	data    []byte
+	leaseID string
+}
+
+// fakeBlobStorage in-process Azure blob storage API with the blob leases support.
+type fakeBlobStorage struct {
+	mux   sync.Mutex
+	blobs map[string]*fakeBlob
+	// failLeasedUploads fails the uploads to the leased blobs.
+	failLeasedUploads bool
+}
+
+func (f *fakeBlobStorage) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// checkLease checks that the request has the lease ID of the leased blob.
+func (f *fakeBlobStorage) checkLease(w http.ResponseWriter, r *http.Request, b *fakeBlob) bool {
+	leaseID := r.Header.Get("x-ms-lease-id")
+	switch {
+	case b.leaseID == "":
+		return true
+	case leaseID == "":
+		f.writeError(w, http.StatusPreconditionFailed, "LeaseIdMissing")
+	case leaseID != b.leaseID:
+		f.writeError(w, http.StatusPreconditionFailed, "LeaseIdMismatchWithBlobOperation")
+	default:
+		return true
+	}
+	return false
+}
+
+func (f *fakeBlobStorage) lease(w http.ResponseWriter, r *http.Request, b *fakeBlob) {
+	switch r.Header.Get("x-ms-lease-action") {
+	case "acquire":
+		proposed := r.Header.Get("x-ms-proposed-lease-id")
+		if b.leaseID != "" && b.leaseID != proposed {
+			f.writeError(w, http.StatusConflict, "LeaseAlreadyPresent")
+			return
+		}
+		b.leaseID = proposed
+		w.Header().Set("x-ms-lease-id", proposed)
+		w.WriteHeader(http.StatusCreated)
+	case "release":
+		if b.leaseID != r.Header.Get("x-ms-lease-id") {
+			f.writeError(w, http.StatusConflict, "LeaseIdMismatchWithLeaseOperation")
+			return
+		}
+		b.leaseID = ""
+		w.WriteHeader(http.StatusOK)
+	case "break":
+		if b.leaseID == "" {
+			f.writeError(w, http.StatusConflict, "LeaseNotPresentWithLeaseOperation")
+			return
+		}
+		b.leaseID = ""
+		w.Header().Set("x-ms-lease-time", "0")
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		f.writeError(w, http.StatusBadRequest, "InvalidHeaderValue")
+	}
+}
+
+func (f *fakeBlobStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	b, exists := f.blobs[r.URL.Path]
+	if !exists && !(r.Method == http.MethodPut && r.URL.Query().Get("comp") == "") {
+		f.writeError(w, http.StatusNotFound, "BlobNotFound")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if r.URL.Query().Get("comp") == "lease" {
+			f.lease(w, r, b)
+			return
+		}
+		if exists && r.Header.Get("If-None-Match") == "*" {
+			f.writeError(w, http.StatusConflict, "BlobAlreadyExists")
+			return
+		}
+		if exists && !f.checkLease(w, r, b) {
+			return
+		}
+		if exists && b.leaseID != "" && f.failLeasedUploads {
+			f.writeError(w, http.StatusForbidden, "AuthorizationFailure")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+		if !exists {
+			b = &fakeBlob{}
+			f.blobs[r.URL.Path] = b
+		}
+		b.data = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		w.Header().Set("Content-Length", fmt.Sprint(len(b.data)))
+		w.Header().Set("ETag", "\"0x1\"")
+		if b.leaseID != "" {
+			w.Header().Set("x-ms-lease-state", "leased")
+		} else {
+			w.Header().Set("x-ms-lease-state", "available")
+		}
+		w.Write(b.data)
+	case http.MethodDelete:
+		if !f.checkLease(w, r, b) {
+			return
+		}
+		delete(f.blobs, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "UnsupportedHttpVerb")
+	}
+}
+
+func newTestClient(t *testing.T, storage *fakeBlobStorage) *azblob.Client {
+	srv := httptest.NewServer(storage)
+	t.Cleanup(srv.Close)
+	client, err := azblob.NewClientWithNoCredential(srv.URL+"/", &azblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{Retry: policy.RetryOptions{MaxRetries: -1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestConformance(t *testing.T) {
+	client := newTestClient(t, &fakeBlobStorage{blobs: map[string]*fakeBlob{}})
+	p := project.NewEmptyProject()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return &Backend{
+			name:          "test",
+			ContainerName: "test-container",
+			ProjectPtr:    p,
+			client:        client,
+		}
+	})
+}
+
+func TestLockReleasesLeaseOnFailure(t *testing.T) {
+	storage := &fakeBlobStorage{blobs: map[string]*fakeBlob{}, failLeasedUploads: true}
+	b := &Backend{
+		name:          "test",
+		ContainerName: "test-container",
+		ProjectPtr:    project.NewEmptyProject(),
+		client:        newTestClient(t, storage),
+	}
+	if err := b.LockState("lock info"); err == nil {
+		t.Fatal("expected lock error")
+	}
+	if b.leaseID != "" {
+		t.Errorf("lease ID was not cleared: %v", b.leaseID)
+	}
+	storage.failLeasedUploads = false
+	if err := b.LockState("lock info"); err != nil {
+		t.Fatalf("lease was not released after the failed lock: %v", err)
+	}
+	if err := b.UnlockState(); err != nil {
+		t.Fatal(err)
+	}
+}
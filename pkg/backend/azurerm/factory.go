@@ -1,6 +1,8 @@
 package azurerm
 
 import (
+	"fmt"
+
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/utils"
@@ -26,6 +28,21 @@ func (f *Factory) New(config []byte, name string, p *project.Project) (project.B
 	return &bk, bk.Configure()
 }
 
+// CheckSpec checks the backend spec without creating the azurerm client.
+func (f *Factory) CheckSpec(config []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(config, &bk); err != nil {
+		return fmt.Errorf("azurerm backend: %w", err)
+	}
+	if bk.StorageAccountName == "" {
+		return fmt.Errorf("azurerm backend: 'storage_account_name' is required")
+	}
+	if bk.ContainerName == "" {
+		return fmt.Errorf("azurerm backend: 'container_name' is required")
+	}
+	return nil
+}
+
 func init() {
 	log.Debug("Registering backend provider azurerm..")
 	if err := project.RegisterBackendFactory(&Factory{}, "azurerm"); err != nil {
@@ -0,0 +1,215 @@
+// Package backendtest contains the conformance test suite for state backends.
+// Each backend runs it against its in-process storage fake.
+package backendtest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+// NewBackendFunc creates a new backend instance. All instances created by the same function
+// must use the same storage, like different cdev processes working with one project.
+type NewBackendFunc func(t *testing.T) project.Backend
+
+// concurrentLockers number of backend instances trying to lock the state at the same time.
+const concurrentLockers = 10
+
+// RunConformance runs the state backend conformance tests.
+func RunConformance(t *testing.T, newBackend NewBackendFunc) {
+	t.Run("ReadEmptyState", func(t *testing.T) {
+		testReadEmptyState(t, newBackend)
+	})
+	t.Run("WriteReadState", func(t *testing.T) {
+		testWriteReadState(t, newBackend)
+	})
+	t.Run("LockUnlock", func(t *testing.T) {
+		testLockUnlock(t, newBackend)
+	})
+	t.Run("ForceUnlock", func(t *testing.T) {
+		testForceUnlock(t, newBackend)
+	})
+	t.Run("ConcurrentLock", func(t *testing.T) {
+		testConcurrentLock(t, newBackend)
+	})
+	t.Run("StateHistory", func(t *testing.T) {
+		testStateHistory(t, newBackend)
+	})
+}
+
+func testReadEmptyState(t *testing.T, newBackend NewBackendFunc) {
+	state, err := newBackend(t).ReadState()
+	if err != nil {
+		t.Fatalf("read missing state: %v", err)
+	}
+	if state != "" {
+		t.Fatalf("read missing state: expected empty state, got %q", state)
+	}
+}
+
+func testWriteReadState(t *testing.T, newBackend NewBackendFunc) {
+	writer, reader := newBackend(t), newBackend(t)
+	for _, data := range []string{`{"version":"1"}`, `{"version":"2"}`} {
+		if err := writer.WriteState(data); err != nil {
+			t.Fatalf("write state: %v", err)
+		}
+		state, err := reader.ReadState()
+		if err != nil {
+			t.Fatalf("read state: %v", err)
+		}
+		if state != data {
+			t.Fatalf("read state: expected %q, got %q", data, state)
+		}
+	}
+}
+
+// checkLock checks that the state lock is held with the expected lock info. Empty info means not locked.
+func checkLock(t *testing.T, b project.Backend, expected string) {
+	t.Helper()
+	info, locked, err := b.ReadLock()
+	if err != nil {
+		t.Fatalf("read lock: %v", err)
+	}
+	if locked != (expected != "") {
+		t.Fatalf("read lock: expected locked=%v, got %v", expected != "", locked)
+	}
+	if info != expected {
+		t.Fatalf("read lock: expected lock info %q, got %q", expected, info)
+	}
+}
+
+func testLockUnlock(t *testing.T, newBackend NewBackendFunc) {
+	first, second := newBackend(t), newBackend(t)
+	checkLock(t, second, "")
+	if err := first.LockState(`{"id":"first"}`); err != nil {
+		t.Fatalf("lock state: %v", err)
+	}
+	checkLock(t, second, `{"id":"first"}`)
+	if err := first.LockState(`{"id":"first"}`); !errors.Is(err, project.ErrStateLocked) {
+		t.Fatalf("lock state twice: expected ErrStateLocked, got %v", err)
+	}
+	if err := second.LockState(`{"id":"second"}`); !errors.Is(err, project.ErrStateLocked) {
+		t.Fatalf("lock locked state: expected ErrStateLocked, got %v", err)
+	}
+	// The failed attempt should not change the lock info.
+	checkLock(t, second, `{"id":"first"}`)
+	// The state should stay writable for the lock owner.
+	if err := first.WriteState(`{"locked":true}`); err != nil {
+		t.Fatalf("write locked state: %v", err)
+	}
+	if err := first.UnlockState(); err != nil {
+		t.Fatalf("unlock state: %v", err)
+	}
+	checkLock(t, second, "")
+	if err := second.LockState(`{"id":"second"}`); err != nil {
+		t.Fatalf("lock unlocked state: %v", err)
+	}
+	checkLock(t, first, `{"id":"second"}`)
+	if err := second.UnlockState(); err != nil {
+		t.Fatalf("unlock state: %v", err)
+	}
+}
+
+func testForceUnlock(t *testing.T, newBackend NewBackendFunc) {
+	owner, other := newBackend(t), newBackend(t)
+	if err := owner.LockState(`{"id":"owner"}`); err != nil {
+		t.Fatalf("lock state: %v", err)
+	}
+	if err := other.UnlockState(); err != nil {
+		t.Fatalf("force unlock state: %v", err)
+	}
+	checkLock(t, other, "")
+	if err := other.LockState(`{"id":"other"}`); err != nil {
+		t.Fatalf("lock state after force unlock: %v", err)
+	}
+	if err := other.UnlockState(); err != nil {
+		t.Fatalf("unlock state: %v", err)
+	}
+}
+
+func testConcurrentLock(t *testing.T, newBackend NewBackendFunc) {
+	backends := make([]project.Backend, concurrentLockers)
+	for i := range backends {
+		backends[i] = newBackend(t)
+	}
+	errs := make([]error, concurrentLockers)
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := range backends {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = backends[i].LockState(fmt.Sprintf(`{"id":"%d"}`, i))
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner >= 0:
+			t.Fatalf("concurrent lock: both lockers %v and %v got the lock", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, project.ErrStateLocked):
+			t.Fatalf("concurrent lock: unexpected error: %v", err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("concurrent lock: nobody got the lock")
+	}
+	checkLock(t, backends[0], fmt.Sprintf(`{"id":"%d"}`, winner))
+	if err := backends[winner].UnlockState(); err != nil {
+		t.Fatalf("unlock state: %v", err)
+	}
+}
+
+func testStateHistory(t *testing.T, newBackend NewBackendFunc) {
+	b, ok := newBackend(t).(project.StateHistoryBackend)
+	if !ok {
+		t.Skip("the backend does not support the state history")
+	}
+	history, err := b.ReadStateHistory()
+	if err != nil {
+		t.Fatalf("read missing state history: %v", err)
+	}
+	if history != "" {
+		t.Fatalf("read missing state history: expected empty history, got %q", history)
+	}
+	if err := b.WriteStateHistory(`{"versions":[1,2]}`); err != nil {
+		t.Fatalf("write state history: %v", err)
+	}
+	if history, err = b.ReadStateHistory(); err != nil || history != `{"versions":[1,2]}` {
+		t.Fatalf("read state history: got %q, %v", history, err)
+	}
+	for _, version := range []int{1, 2} {
+		if err := b.WriteStateVersion(version, fmt.Sprintf(`{"v":%d}`, version)); err != nil {
+			t.Fatalf("write state version: %v", err)
+		}
+	}
+	for _, version := range []int{1, 2} {
+		data, err := b.ReadStateVersion(version)
+		if err != nil {
+			t.Fatalf("read state version: %v", err)
+		}
+		if expected := fmt.Sprintf(`{"v":%d}`, version); data != expected {
+			t.Fatalf("read state version %v: expected %q, got %q", version, expected, data)
+		}
+	}
+	if err := b.DeleteStateVersion(1); err != nil {
+		t.Fatalf("delete state version: %v", err)
+	}
+	if err := b.DeleteStateVersion(1); err != nil {
+		t.Fatalf("delete missing state version: %v", err)
+	}
+	if _, err := b.ReadStateVersion(1); !errors.Is(err, project.ErrStateVersionNotFound) {
+		t.Fatalf("read deleted state version: expected ErrStateVersionNotFound, got %v", err)
+	}
+	if _, err := b.ReadStateVersion(2); err != nil {
+		t.Fatalf("read state version: %v", err)
+	}
+}
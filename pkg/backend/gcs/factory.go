@@ -1,6 +1,8 @@
 package gcs
 
 import (
+	"fmt"
+
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/utils"
@@ -28,6 +30,18 @@ func (f *Factory) New(config []byte, name string, p *project.Project) (project.B
 	return &bk, bk.Configure()
 }
 
+// CheckSpec checks the backend spec without creating the gcs client.
+func (f *Factory) CheckSpec(config []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(config, &bk); err != nil {
+		return fmt.Errorf("gcs backend: %w", err)
+	}
+	if bk.Bucket == "" {
+		return fmt.Errorf("gcs backend: 'bucket' is required")
+	}
+	return nil
+}
+
 func init() {
 	log.Debug("Registering backend provider gcs..")
 	if err := project.RegisterBackendFactory(&Factory{}, "gcs"); err != nil {
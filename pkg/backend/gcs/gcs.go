@@ -4,18 +4,23 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"github.com/apex/log"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/shalb/cluster.dev/pkg/hcltools"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/utils"
 	"github.com/zclconf/go-cty/cty"
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 	"gopkg.in/yaml.v3"
@@ -33,6 +38,7 @@ type Backend struct {
 	AccessToken            string                 `yaml:"access_token,omitempty"`
 	Prefix                 string                 `yaml:"prefix"`
 	encryptionKey          []byte                 `yaml:"encryption_key,omitempty"`
+	lockGeneration         int64                  `yaml:"-"`
 	StorageCustomEndpoint  string                 `yaml:"storage_custom_endpoint,omitempty"`
 	state                  map[string]interface{} `yaml:"-"`
 	ProjectPtr             *project.Project       `yaml:"-"`
@@ -157,7 +163,7 @@ func (b *Backend) GetBackendHCL(stackName, unitName string) (*hclwrite.File, err
 	if err != nil {
 		return nil, err
 	}
-	bConfigTmpl["prefix"] = fmt.Sprintf("%s%s_%s", b.Prefix, stackName, unitName)
+	bConfigTmpl["prefix"] = fmt.Sprintf("%s%s%s_%s", b.Prefix, b.envPrefix(), stackName, unitName)
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 	terraformBlock := rootBody.AppendNewBlock("terraform", []string{})
@@ -175,7 +181,7 @@ func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error)
 	if err != nil {
 		return nil, err
 	}
-	bConfigTmpl["prefix"] = fmt.Sprintf("%s%s_%s", b.Prefix, stackName, unitName)
+	bConfigTmpl["prefix"] = fmt.Sprintf("%s%s%s_%s", b.Prefix, b.envPrefix(), stackName, unitName)
 	f := hclwrite.NewEmptyFile()
 
 	rootBody := f.Body()
@@ -201,92 +207,186 @@ func getStateMap(in Backend) (res map[string]interface{}, err error) {
 	return
 }
 
-func (b *Backend) LockState() error {
-	lockKey := fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name())
+func (b *Backend) LockState(lockInfo string) error {
+	lockKey := b.lockKey()
+	log.Debugf("Locking gcs state. Project: '%v', bucket: '%v'", b.ProjectPtr.Name(), b.Bucket)
 
 	// Create a context.
 	ctx := context.Background()
 
-	// Check if the lock object exists.
-	_, err := b.storageClient.Bucket(b.Bucket).Object(lockKey).Attrs(ctx)
-	if err == nil {
-		return fmt.Errorf("lock state file found, the state is locked")
-	}
-
-	sessionID := utils.RandString(10)
-
-	// Create the lock object with the sessionID.
-	lockObject := b.storageClient.Bucket(b.Bucket).Object(lockKey)
+	// Create the lock object with the generation precondition: GCS writes it only if the object
+	// does not exist yet, so only one of the concurrent lockers succeeds.
+	lockObject := b.storageClient.Bucket(b.Bucket).Object(lockKey).If(storage.Conditions{DoesNotExist: true})
 	w := lockObject.NewWriter(ctx)
-	defer w.Close()
+	if _, err := w.Write([]byte(lockInfo)); err != nil {
+		w.Close()
+		return fmt.Errorf("lock state: can't save lock state file: %w", err)
+	}
+	// The object is created on close, the precondition is checked here.
+	if err := w.Close(); err != nil {
+		var gErr *googleapi.Error
+		if errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("lock state: %w, lock file '%v' found in bucket '%v'. Use command 'cdev state unlock' to force unlock (unsafe)", project.ErrStateLocked, lockKey, b.Bucket)
+		}
+		return fmt.Errorf("lock state: can't save lock state file: %w", err)
+	}
+	b.lockGeneration = w.Attrs().Generation
+	return nil
+}
 
-	if _, err := w.Write([]byte(sessionID)); err != nil {
-		return fmt.Errorf("can't save lock state file: %v", err.Error())
+func (b *Backend) ReadLock() (string, bool, error) {
+	lockInfo, locked, err := b.readObject(b.lockKey())
+	if err != nil {
+		return "", false, fmt.Errorf("read lock: %w", err)
 	}
+	return lockInfo, locked, nil
+}
 
-	// Sleep and read the sessionID from the lock object.
-	// Compare it with the generated sessionID.
+func (b *Backend) UnlockState() error {
+	lockKey := b.lockKey()
+	log.Debugf("Unlocking gcs state. Project: '%v', bucket: '%v'", b.ProjectPtr.Name(), b.Bucket)
 
+	// Create a context.
+	ctx := context.Background()
+
+	// Delete the lock object. If the lock was acquired by this process, delete only our own generation of it.
+	lockObject := b.storageClient.Bucket(b.Bucket).Object(lockKey)
+	if b.lockGeneration != 0 {
+		lockObject = lockObject.If(storage.Conditions{GenerationMatch: b.lockGeneration})
+	}
+	err := lockObject.Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("unlock state: %w", err)
+	}
+	b.lockGeneration = 0
 	return nil
 }
-func (b *Backend) UnlockState() error {
-	lockKey := fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name())
 
+// readObject reads the object from the bucket. Returns false if the object does not exist.
+func (b *Backend) readObject(key string) (string, bool, error) {
 	// Create a context.
 	ctx := context.Background()
 
-	// Delete the lock object.
-	return b.storageClient.Bucket(b.Bucket).Object(lockKey).Delete(ctx)
+	r, err := b.storageClient.Bucket(b.Bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
 }
 
-func (b *Backend) WriteState(stateData string) error {
-	stateKey := fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name())
-
+// writeObject creates or overwrites the object.
+func (b *Backend) writeObject(key, data string) error {
 	// Create a context.
 	ctx := context.Background()
 
-	// Create or overwrite the state object with stateData.
-	stateObject := b.storageClient.Bucket(b.Bucket).Object(stateKey)
-	w := stateObject.NewWriter(ctx)
-	defer w.Close()
+	w := b.storageClient.Bucket(b.Bucket).Object(key).NewWriter(ctx)
+	if _, err := w.Write([]byte(data)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *Backend) deleteObject(key string) error {
+	// Create a context.
+	ctx := context.Background()
 
-	if _, err := w.Write([]byte(stateData)); err != nil {
-		return fmt.Errorf("can't save state file: %v", err.Error())
+	err := b.storageClient.Bucket(b.Bucket).Object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return err
 	}
+	return nil
+}
 
+func (b *Backend) WriteState(stateData string) error {
+	err := b.writeObject(b.stateKey(), stateData)
+	if err != nil {
+		return fmt.Errorf("can't save state file: %w", err)
+	}
 	return nil
 }
+
 func (b *Backend) ReadState() (string, error) {
-	stateKey := fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name())
+	stateData, _, err := b.readObject(b.stateKey())
+	if err != nil {
+		return "", fmt.Errorf("read state: %w", err)
+	}
+	return stateData, nil
+}
 
-	// Create a context.
-	ctx := context.Background()
+func (b *Backend) ReadStateHistory() (string, error) {
+	history, _, err := b.readObject(b.historyKey())
+	if err != nil {
+		return "", fmt.Errorf("read state history: %w", err)
+	}
+	return history, nil
+}
 
-	// Check if the object exists.
-	_, err := b.storageClient.Bucket(b.Bucket).Object(stateKey).Attrs(ctx)
+func (b *Backend) WriteStateHistory(history string) error {
+	err := b.writeObject(b.historyKey(), history)
 	if err != nil {
-		if err == storage.ErrObjectNotExist {
-			// fmt.Printf("Object '%s' does not exist in bucket '%s'\n", stateKey, b.Bucket)
-			return "", nil
-		}
-		fmt.Printf("Error checking object existence: %v\n", err)
-		return "", err
+		return fmt.Errorf("can't save state history: %w", err)
 	}
+	return nil
+}
 
-	// Read the state object.
-	stateObject := b.storageClient.Bucket(b.Bucket).Object(stateKey)
-	r, err := stateObject.NewReader(ctx)
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	stateData, exists, err := b.readObject(b.stateVersionKey(version))
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("read state version %v: %w", version, err)
 	}
-	defer r.Close()
+	if !exists {
+		return "", fmt.Errorf("read state version %v: %w", version, project.ErrStateVersionNotFound)
+	}
+	return stateData, nil
+}
 
-	stateData, err := ioutil.ReadAll(r)
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	err := b.writeObject(b.stateVersionKey(version), stateData)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("can't save state version %v: %w", version, err)
 	}
+	return nil
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	err := b.deleteObject(b.stateVersionKey(version))
+	if err != nil {
+		return fmt.Errorf("delete state version %v: %w", version, err)
+	}
+	return nil
+}
+
+// envPrefix returns the key prefix of the active environment states.
+func (b *Backend) envPrefix() string {
+	if b.ProjectPtr.Env() == "" {
+		return ""
+	}
+	return b.ProjectPtr.Env() + "/"
+}
+
+func (b *Backend) stateKey() string {
+	return fmt.Sprintf("%scdev.%s.state", b.envPrefix(), b.ProjectPtr.Name())
+}
+
+func (b *Backend) lockKey() string {
+	return fmt.Sprintf("%scdev.%s.lock", b.envPrefix(), b.ProjectPtr.Name())
+}
+
+func (b *Backend) stateVersionKey(version int) string {
+	return fmt.Sprintf("%s.%d", b.stateKey(), version)
+}
 
-	return string(stateData), nil
+func (b *Backend) historyKey() string {
+	return fmt.Sprintf("%scdev.%s.history", b.envPrefix(), b.ProjectPtr.Name())
 }
 
 // ReadPathOrContents reads the contents of a file if the input is a file path,
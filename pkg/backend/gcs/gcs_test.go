@@ -0,0 +1,156 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"google.golang.org/api/option"
+)
+
+type fakeGCSObject struct {
+	data       []byte
+	generation int64
+}
+
+// fakeGCS in-process GCS JSON API with the generation preconditions support.
+type fakeGCS struct {
+	mux        sync.Mutex
+	objects    map[string]fakeGCSObject
+	generation int64
+}
+
+func (f *fakeGCS) writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, status, http.StatusText(status))
+}
+
+func (f *fakeGCS) writeObject(w http.ResponseWriter, bucket, name string, obj fakeGCSObject) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bucket":     bucket,
+		"name":       name,
+		"generation": strconv.FormatInt(obj.generation, 10),
+		"size":       strconv.Itoa(len(obj.data)),
+	})
+}
+
+// checkPreconditions checks ifGenerationMatch query parameter. Zero generation means the object should not exist.
+func (f *fakeGCS) checkPreconditions(r *http.Request, key string) bool {
+	val := r.URL.Query().Get("ifGenerationMatch")
+	if val == "" {
+		return true
+	}
+	gen, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return false
+	}
+	obj, exists := f.objects[key]
+	if gen == 0 {
+		return !exists
+	}
+	return exists && obj.generation == gen
+}
+
+// readUpload reads object name and data from the multipart upload request.
+func readUpload(r *http.Request) (string, []byte, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil, err
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	metaPart, err := mr.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	meta := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(metaPart).Decode(&meta); err != nil {
+		return "", nil, err
+	}
+	dataPart, err := mr.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(dataPart)
+	return meta.Name, data, err
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	// Upload: /upload/storage/v1/b/<bucket>/o
+	case r.Method == http.MethodPost && len(path) == 6 && path[0] == "upload":
+		name, data, err := readUpload(r)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest)
+			return
+		}
+		key := path[4] + "/" + name
+		if !f.checkPreconditions(r, key) {
+			f.writeError(w, http.StatusPreconditionFailed)
+			return
+		}
+		f.generation++
+		f.objects[key] = fakeGCSObject{data: data, generation: f.generation}
+		f.writeObject(w, path[4], name, f.objects[key])
+	// Delete: /storage/v1/b/<bucket>/o/<object>
+	case r.Method == http.MethodDelete && len(path) == 6 && path[0] == "storage":
+		key := path[3] + "/" + path[5]
+		if _, exists := f.objects[key]; !exists {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		if !f.checkPreconditions(r, key) {
+			f.writeError(w, http.StatusPreconditionFailed)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	// Download: /<bucket>/<object>
+	case r.Method == http.MethodGet && len(path) == 2:
+		obj, exists := f.objects[path[0]+"/"+path[1]]
+		if !exists {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Goog-Generation", strconv.FormatInt(obj.generation, 10))
+		w.Write(obj.data)
+	default:
+		f.writeError(w, http.StatusNotImplemented)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(&fakeGCS{objects: map[string]fakeGCSObject{}})
+	defer srv.Close()
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := project.NewEmptyProject()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return &Backend{
+			name:          "test",
+			Bucket:        "test-bucket",
+			ProjectPtr:    p,
+			storageClient: client,
+		}
+	})
+}
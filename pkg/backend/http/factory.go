@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Factory factory for http backends.
+type Factory struct{}
+
+// New creates the new http backend.
+func (f *Factory) New(config []byte, name string, p *project.Project) (project.Backend, error) {
+	bk := Backend{
+		name:       name,
+		ProjectPtr: p,
+	}
+	err := yaml.Unmarshal(config, &bk)
+	if err != nil {
+		return nil, utils.ResolveYamlError(config, err)
+	}
+	return &bk, bk.Configure()
+}
+
+// CheckSpec checks the backend spec. No requests are sent to the server.
+func (f *Factory) CheckSpec(config []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(config, &bk); err != nil {
+		return fmt.Errorf("http backend: %w", err)
+	}
+	return bk.Configure()
+}
+
+func init() {
+	log.Debug("Registering backend provider http..")
+	if err := project.RegisterBackendFactory(&Factory{}, "http"); err != nil {
+		log.Trace("Can't register backend provider http.")
+	}
+}
@@ -0,0 +1,489 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/google/uuid"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// requestTimeout timeout of a single request to the state server.
+const requestTimeout = 30 * time.Second
+
+// probeUnlockAttempts number of attempts to release the lock taken to read the lock holder.
+const probeUnlockAttempts = 3
+
+// probeUnlockDelay delay between the attempts to release the probe lock.
+var probeUnlockDelay = 2 * time.Second
+
+// Backend - describe http backend for interface package.backend. It implements the Terraform http backend
+// protocol: the state is read with GET, written with the update method, and locked with the lock and unlock
+// methods on the lock address.
+type Backend struct {
+	name   string       `yaml:"-"`
+	client *http.Client `yaml:"-"`
+	// lockID ID of the lock held by this instance, empty if the lock is not held.
+	lockID string `yaml:"-"`
+
+	Address              string `yaml:"address"`
+	UpdateMethod         string `yaml:"update_method,omitempty"`
+	LockMethod           string `yaml:"lock_method,omitempty"`
+	UnlockMethod         string `yaml:"unlock_method,omitempty"`
+	LockSuffix           string `yaml:"lock_suffix,omitempty"`
+	Username             string `yaml:"username,omitempty"`
+	Password             string `yaml:"password,omitempty"`
+	SkipCertVerification bool   `yaml:"skip_cert_verification,omitempty"`
+
+	ProjectPtr *project.Project `yaml:"-"`
+}
+
+// lockBody the lock info in the Terraform format, sent with the lock and unlock requests.
+// The server returns the body of the current lock if the state is already locked.
+type lockBody struct {
+	ID        string    `json:"ID"`
+	Operation string    `json:"Operation"`
+	Info      string    `json:"Info"`
+	Who       string    `json:"Who"`
+	Version   string    `json:"Version"`
+	Created   time.Time `json:"Created"`
+	Path      string    `json:"Path"`
+}
+
+// Name return name.
+func (b *Backend) Name() string {
+	return b.name
+}
+
+// Provider return name.
+func (b *Backend) Provider() string {
+	return "http"
+}
+
+// Configure checks the backend options and sets the defaults.
+func (b *Backend) Configure() error {
+	if b.Address == "" {
+		return fmt.Errorf("configure http backend: 'address' is required")
+	}
+	u, err := url.Parse(b.Address)
+	if err != nil {
+		return fmt.Errorf("configure http backend: parse address: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("configure http backend: unsupported address scheme '%v', expected http or https", u.Scheme)
+	}
+	b.Address = strings.TrimSuffix(b.Address, "/")
+	if b.UpdateMethod == "" {
+		b.UpdateMethod = http.MethodPost
+	}
+	if b.LockMethod == "" {
+		b.LockMethod = "LOCK"
+	}
+	if b.UnlockMethod == "" {
+		b.UnlockMethod = "UNLOCK"
+	}
+	if b.LockSuffix == "" {
+		b.LockSuffix = "/lock"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if b.SkipCertVerification {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	b.client = &http.Client{Transport: transport, Timeout: requestTimeout}
+	return nil
+}
+
+// credentials returns the basic auth credentials. The same as Terraform, cdev reads them from
+// TF_HTTP_USERNAME and TF_HTTP_PASSWORD environment variables if they are not set in the backend spec.
+func (b *Backend) credentials() (string, string) {
+	username, password := b.Username, b.Password
+	if username == "" {
+		username = os.Getenv("TF_HTTP_USERNAME")
+	}
+	if password == "" {
+		password = os.Getenv("TF_HTTP_PASSWORD")
+	}
+	return username, password
+}
+
+// GetBackendBytes generate terraform backend config.
+func (b *Backend) GetBackendBytes(stackName, unitName string) ([]byte, error) {
+	f, err := b.GetBackendHCL(stackName, unitName)
+	if err != nil {
+		return nil, err
+	}
+	return f.Bytes(), nil
+}
+
+// GetBackendHCL generate terraform backend config.
+func (b *Backend) GetBackendHCL(stackName, unitName string) (*hclwrite.File, error) {
+	f := hclwrite.NewEmptyFile()
+	rootBody := f.Body()
+	terraformBlock := rootBody.AppendNewBlock("terraform", []string{})
+	backendBlock := terraformBlock.Body().AppendNewBlock("backend", []string{"http"})
+	backendBody := backendBlock.Body()
+	for key, val := range b.terraformConfig(stackName, unitName) {
+		backendBody.SetAttributeValue(key, val)
+	}
+	return f, nil
+}
+
+// GetRemoteStateHCL generate terraform remote state for this backend.
+func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error) {
+	f := hclwrite.NewEmptyFile()
+	rootBody := f.Body()
+	dataBlock := rootBody.AppendNewBlock("data", []string{"terraform_remote_state", fmt.Sprintf("%s-%s", stackName, unitName)})
+	dataBody := dataBlock.Body()
+	dataBody.SetAttributeValue("backend", cty.StringVal("http"))
+	dataBody.SetAttributeValue("config", cty.ObjectVal(b.terraformConfig(stackName, unitName)))
+	return f.Bytes(), nil
+}
+
+// terraformConfig returns the terraform http backend options for the unit state. Credentials from
+// the environment variables are not written to the generated code, terraform reads them itself.
+func (b *Backend) terraformConfig(stackName, unitName string) map[string]cty.Value {
+	address := b.unitStateAddress(stackName, unitName)
+	res := map[string]cty.Value{
+		"address":        cty.StringVal(address),
+		"update_method":  cty.StringVal(b.UpdateMethod),
+		"lock_address":   cty.StringVal(address + b.LockSuffix),
+		"lock_method":    cty.StringVal(b.LockMethod),
+		"unlock_address": cty.StringVal(address + b.LockSuffix),
+		"unlock_method":  cty.StringVal(b.UnlockMethod),
+	}
+	if b.Username != "" {
+		res["username"] = cty.StringVal(b.Username)
+	}
+	if b.Password != "" {
+		res["password"] = cty.StringVal(b.Password)
+	}
+	if b.SkipCertVerification {
+		res["skip_cert_verification"] = cty.True
+	}
+	return res
+}
+
+// stateAddress returns the address of the state. The states of the active environment have the '<env>.' name prefix.
+func (b *Backend) stateAddress(name string) string {
+	if env := b.ProjectPtr.Env(); env != "" {
+		name = fmt.Sprintf("%s.%s", env, name)
+	}
+	return fmt.Sprintf("%s/%s", b.Address, url.PathEscape(name))
+}
+
+// unitStateAddress address of the unit terraform state. The project state name always contains two dots,
+// so it can't be the same as the unit state name.
+func (b *Backend) unitStateAddress(stackName, unitName string) string {
+	return b.stateAddress(fmt.Sprintf("%s.%s", stackName, unitName))
+}
+
+func (b *Backend) projectStateAddress() string {
+	return b.stateAddress(fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name()))
+}
+
+func (b *Backend) stateVersionAddress(version int) string {
+	return b.stateAddress(fmt.Sprintf("cdev.%s.state.%d", b.ProjectPtr.Name(), version))
+}
+
+func (b *Backend) historyAddress() string {
+	return b.stateAddress(fmt.Sprintf("cdev.%s.history", b.ProjectPtr.Name()))
+}
+
+func (b *Backend) lockAddress() string {
+	return b.projectStateAddress() + b.LockSuffix
+}
+
+// do sends the request and returns the response status and body.
+func (b *Backend) do(method, address string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, address, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if username, password := b.credentials(); username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("read response body: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// statusError describes the unexpected response.
+func statusError(status int, body []byte) error {
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	if msg == "" {
+		return fmt.Errorf("unexpected response status %v %v", status, http.StatusText(status))
+	}
+	return fmt.Errorf("unexpected response status %v %v: %v", status, http.StatusText(status), msg)
+}
+
+// getObject reads the object with GET. Returns false if the object does not exist.
+func (b *Backend) getObject(address string) (string, bool, error) {
+	status, body, err := b.do(http.MethodGet, address, nil)
+	if err != nil {
+		return "", false, err
+	}
+	switch status {
+	case http.StatusOK:
+		return string(body), true, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		return "", false, nil
+	}
+	return "", false, statusError(status, body)
+}
+
+func (b *Backend) putObject(address, data string) error {
+	status, body, err := b.do(b.UpdateMethod, address, []byte(data))
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+	return statusError(status, body)
+}
+
+func (b *Backend) deleteObject(address string) error {
+	status, body, err := b.do(http.MethodDelete, address, nil)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return statusError(status, body)
+}
+
+func (b *Backend) ReadState() (string, error) {
+	state, _, err := b.getObject(b.projectStateAddress())
+	if err != nil {
+		return "", fmt.Errorf("get state from http backend: %w", err)
+	}
+	return state, nil
+}
+
+func (b *Backend) WriteState(stateData string) error {
+	address := b.projectStateAddress()
+	if b.lockID != "" {
+		// The servers with locking support check that the state is updated by the lock holder.
+		address += "?ID=" + url.QueryEscape(b.lockID)
+	}
+	err := b.putObject(address, stateData)
+	if err != nil {
+		return fmt.Errorf("write state to http backend: %w", err)
+	}
+	return nil
+}
+
+func (b *Backend) ReadStateHistory() (string, error) {
+	history, _, err := b.getObject(b.historyAddress())
+	if err != nil {
+		return "", fmt.Errorf("get state history from http backend: %w", err)
+	}
+	return history, nil
+}
+
+func (b *Backend) WriteStateHistory(history string) error {
+	err := b.putObject(b.historyAddress(), history)
+	if err != nil {
+		return fmt.Errorf("write state history to http backend: %w", err)
+	}
+	return nil
+}
+
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	state, exists, err := b.getObject(b.stateVersionAddress(version))
+	if err != nil {
+		return "", fmt.Errorf("get state version %v from http backend: %w", version, err)
+	}
+	if !exists {
+		return "", fmt.Errorf("get state version %v from http backend: %w", version, project.ErrStateVersionNotFound)
+	}
+	return state, nil
+}
+
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	err := b.putObject(b.stateVersionAddress(version), stateData)
+	if err != nil {
+		return fmt.Errorf("write state version %v to http backend: %w", version, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	err := b.deleteObject(b.stateVersionAddress(version))
+	if err != nil {
+		return fmt.Errorf("delete state version %v from http backend: %w", version, err)
+	}
+	return nil
+}
+
+// newLockBody creates the Terraform lock info. The cdev lock info is kept in the Info field,
+// the holder fields are filled from it to be shown by the server.
+func (b *Backend) newLockBody(id, operation, lockInfo string) lockBody {
+	body := lockBody{
+		ID:        id,
+		Operation: operation,
+		Info:      lockInfo,
+		Version:   config.Global.Version,
+		Created:   time.Now().UTC(),
+		Path:      b.projectStateAddress(),
+	}
+	if info := project.ParseLockInfo(lockInfo); info.User != "" {
+		body.Who = fmt.Sprintf("%s@%s", info.User, info.Hostname)
+	}
+	return body
+}
+
+// lock sends the lock request. Returns false and the current lock body if the state is already locked.
+func (b *Backend) lock(body lockBody) (bool, []byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return false, nil, err
+	}
+	status, respBody, err := b.do(b.LockMethod, b.lockAddress(), data)
+	if err != nil {
+		return false, nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		return true, nil, nil
+	case http.StatusLocked, http.StatusConflict:
+		return false, respBody, nil
+	}
+	return false, nil, statusError(status, respBody)
+}
+
+func (b *Backend) unlock(body lockBody) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	status, respBody, err := b.do(b.UnlockMethod, b.lockAddress(), data)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return statusError(status, respBody)
+}
+
+func (b *Backend) LockState(lockInfo string) error {
+	log.Debugf("Locking http state. Project: '%v', address: '%v'", b.ProjectPtr.Name(), b.lockAddress())
+	body := b.newLockBody(uuid.New().String(), "cdev", lockInfo)
+	locked, _, err := b.lock(body)
+	if err != nil {
+		return fmt.Errorf("lock state: http backend: %w", err)
+	}
+	if !locked {
+		return fmt.Errorf("lock state: %w, lock found at '%v'. Use command 'cdev state unlock' to force unlock (unsafe)", project.ErrStateLocked, b.lockAddress())
+	}
+	b.lockID = body.ID
+	return nil
+}
+
+// currentLock returns the body of the current lock, nil if the state is not locked. The http protocol has
+// no request to read the lock, so the lock is probed: the server returns the holder if the state is locked.
+// Otherwise the probe takes the lock for a moment and it is released at once. An unlock request with a
+// wrong ID is not used for probing, as some servers release the lock regardless of the ID.
+func (b *Backend) currentLock() (*lockBody, []byte, error) {
+	probe := b.newLockBody(uuid.New().String(), "cdev-read-lock", "")
+	locked, holderData, err := b.lock(probe)
+	if err != nil {
+		return nil, nil, err
+	}
+	if locked {
+		return nil, nil, b.releaseProbe(probe)
+	}
+	holder := lockBody{}
+	if err := json.Unmarshal(holderData, &holder); err != nil {
+		// The server did not return the lock info.
+		return &lockBody{}, holderData, nil
+	}
+	return &holder, holderData, nil
+}
+
+// releaseProbe releases the probe lock. The request is retried, so a single network error does not
+// leave the state locked.
+func (b *Backend) releaseProbe(probe lockBody) (err error) {
+	for attempt := 1; attempt <= probeUnlockAttempts; attempt++ {
+		if err = b.unlock(probe); err == nil {
+			return nil
+		}
+		log.Debugf("Releasing the lock probe '%v', attempt %v: %v", probe.ID, attempt, err.Error())
+		if attempt < probeUnlockAttempts {
+			time.Sleep(probeUnlockDelay)
+		}
+	}
+	log.Warnf("The state was locked to read the lock holder and can't be unlocked. Use command 'cdev state unlock' to release the lock '%v'", probe.ID)
+	return fmt.Errorf("release the lock probe '%v': %w", probe.ID, err)
+}
+
+// ProbesLock returns true: the lock is read with the probe lock request, see currentLock.
+func (b *Backend) ProbesLock() bool {
+	return true
+}
+
+func (b *Backend) ReadLock() (string, bool, error) {
+	holder, holderData, err := b.currentLock()
+	if err != nil {
+		return "", false, fmt.Errorf("read lock from http backend: %w", err)
+	}
+	if holder == nil {
+		return "", false, nil
+	}
+	if holder.Info == "" {
+		// Locked by terraform or by the other client.
+		return strings.TrimSpace(string(holderData)), true, nil
+	}
+	return holder.Info, true, nil
+}
+
+func (b *Backend) UnlockState() error {
+	log.Debugf("Unlocking http state. Project: '%v', address: '%v'", b.ProjectPtr.Name(), b.lockAddress())
+	body := lockBody{ID: b.lockID}
+	if b.lockID == "" {
+		// Force unlock: release the lock with the holder ID.
+		holder, _, err := b.currentLock()
+		if err != nil {
+			return fmt.Errorf("unlock state: http backend: %w", err)
+		}
+		if holder == nil {
+			return nil
+		}
+		body = *holder
+	}
+	if err := b.unlock(body); err != nil {
+		return fmt.Errorf("unlock state: http backend: %w", err)
+	}
+	b.lockID = ""
+	return nil
+}
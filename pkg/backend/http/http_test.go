@@ -0,0 +1,201 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+// fakeStateServer in-process state server which implements the Terraform http backend protocol with locking.
+type fakeStateServer struct {
+	mux    sync.Mutex
+	states map[string][]byte
+	locks  map[string]lockBody
+	// failUnlocks number of the next unlock requests to fail.
+	failUnlocks int
+	// lockRequests number of the received lock requests.
+	lockRequests int
+}
+
+func (f *fakeStateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if username, password, ok := r.BasicAuth(); !ok || username != "cdev" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if key, isLock := strings.CutSuffix(r.URL.Path, "/lock"); isLock {
+		f.serveLock(w, r.Method, key, body)
+		return
+	}
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodGet:
+		data, exists := f.states[key]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodPost:
+		if lock, locked := f.locks[key]; locked && lock.ID != r.URL.Query().Get("ID") {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.states[key] = body
+	case http.MethodDelete:
+		delete(f.states, key)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeStateServer) serveLock(w http.ResponseWriter, method, key string, body []byte) {
+	req := lockBody{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	lock, locked := f.locks[key]
+	switch method {
+	case "LOCK":
+		f.lockRequests++
+		if locked {
+			w.WriteHeader(http.StatusLocked)
+			json.NewEncoder(w).Encode(lock)
+			return
+		}
+		f.locks[key] = req
+	case "UNLOCK":
+		if f.failUnlocks > 0 {
+			f.failUnlocks--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if locked && lock.ID != req.ID {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(lock)
+			return
+		}
+		delete(f.locks, key)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestBackend(t *testing.T, address string) *Backend {
+	t.Helper()
+	b := &Backend{
+		name:       "test",
+		Address:    address,
+		Username:   "cdev",
+		Password:   "secret",
+		ProjectPtr: project.NewEmptyProject(),
+	}
+	if err := b.Configure(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(&fakeStateServer{states: map[string][]byte{}, locks: map[string]lockBody{}})
+	defer srv.Close()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return newTestBackend(t, srv.URL+"/states/")
+	})
+}
+
+func TestBackendHCL(t *testing.T) {
+	b := newTestBackend(t, "https://example.com/states")
+	backend, err := b.GetBackendBytes("infra", "vpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteState, err := b.GetRemoteStateHCL("infra", "vpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`backend "http"`,
+		`address        = "https://example.com/states/infra.vpc"`,
+		`lock_address   = "https://example.com/states/infra.vpc/lock"`,
+		`unlock_method  = "UNLOCK"`,
+	} {
+		if !strings.Contains(string(backend), expected) {
+			t.Errorf("backend config does not contain %q:\n%s", expected, backend)
+		}
+	}
+	for _, expected := range []string{
+		`data "terraform_remote_state" "infra-vpc"`,
+		`backend = "http"`,
+		`"https://example.com/states/infra.vpc"`,
+	} {
+		if !strings.Contains(string(remoteState), expected) {
+			t.Errorf("remote state does not contain %q:\n%s", expected, remoteState)
+		}
+	}
+}
+
+func TestReadLockReleasesProbe(t *testing.T) {
+	probeUnlockDelay = 0
+	server := &fakeStateServer{states: map[string][]byte{}, locks: map[string]lockBody{}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	b := newTestBackend(t, srv.URL+"/states/")
+
+	// The first unlock of the probe fails, the retry releases it.
+	server.failUnlocks = 1
+	if _, locked, err := b.ReadLock(); err != nil || locked {
+		t.Fatalf("expected not locked state, got %v, %v", locked, err)
+	}
+	if len(server.locks) != 0 {
+		t.Fatalf("the probe lock was not released: %v", server.locks)
+	}
+
+	// All unlock attempts fail: the probe ID is reported and the lock can be released with force unlock.
+	server.failUnlocks = probeUnlockAttempts
+	_, _, err := b.ReadLock()
+	if err == nil || !strings.Contains(err.Error(), "release the lock probe") {
+		t.Fatalf("expected the probe release error, got %v", err)
+	}
+	info, locked, err := b.ReadLock()
+	if err != nil || !locked || !strings.Contains(info, "cdev-read-lock") {
+		t.Fatalf("expected the probe lock, got %q, %v, %v", info, locked, err)
+	}
+	if err := b.UnlockState(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.locks) != 0 {
+		t.Fatalf("the probe lock was not released by force unlock: %v", server.locks)
+	}
+}
+
+func TestLockInfoDoesNotProbe(t *testing.T) {
+	server := &fakeStateServer{states: map[string][]byte{}, locks: map[string]lockBody{}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	b := newTestBackend(t, srv.URL+"/states/")
+	p := b.ProjectPtr
+	p.Backends = map[string]project.Backend{"test": b}
+	p.StateBackendName = "test"
+
+	if err := p.PrintLockInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if server.lockRequests != 0 || len(server.locks) != 0 {
+		t.Errorf("lock-info should not send lock requests, got %v", server.lockRequests)
+	}
+}
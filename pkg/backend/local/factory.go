@@ -32,6 +32,10 @@ func (f *Factory) New(cnf []byte, name string, p *project.Project) (project.Back
 	if !utils.IsAbsolutePath(bk.Path) {
 		bk.Path = filepath.Join(config.Global.ProjectConfigsPath, bk.Path)
 	}
+	// Environment states are stored in the subdirectory.
+	if env := p.Env(); env != "" {
+		bk.Path = filepath.Join(bk.Path, env)
+	}
 	isDir, err := utils.CheckDir(bk.Path)
 	if isDir {
 		return &bk, nil
@@ -42,6 +46,15 @@ func (f *Factory) New(cnf []byte, name string, p *project.Project) (project.Back
 	return &bk, err
 }
 
+// CheckSpec checks the backend spec without creating the backend dir.
+func (f *Factory) CheckSpec(cnf []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(cnf, &bk); err != nil {
+		return fmt.Errorf("local backend: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	log.Debug("Registering backend provider local..")
 	if err := project.RegisterBackendFactory(&Factory{}, "local"); err != nil {
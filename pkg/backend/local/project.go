@@ -1,31 +1,59 @@
 package local
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/project"
 )
 
 const stateFileName = "cdev-state.json"
 const stateLockFileName = "cdev-state.lock"
+const stateHistoryFileName = "cdev-state.history.json"
 
-func (b *Backend) LockState() error {
+func (b *Backend) LockState(lockInfo string) error {
 	stateLockFilePath := filepath.Join(b.Path, stateLockFileName)
+	log.Debugf("Locking local state. Path: '%v'", stateLockFilePath)
+	// O_EXCL guarantees that only one process creates the lock file.
+	f, err := os.OpenFile(stateLockFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("lock state: %w, lock file '%v' exists. Use command 'cdev state unlock' to force unlock (unsafe)", project.ErrStateLocked, stateLockFilePath)
+		}
+		return fmt.Errorf("lock state: %w", err)
+	}
+	_, err = f.WriteString(lockInfo)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("lock state: %w", err)
+	}
+	return f.Close()
+}
 
-	_, err := os.ReadFile(stateLockFilePath)
-	if err == nil {
-		return fmt.Errorf("state is locked by another process")
+func (b *Backend) ReadLock() (string, bool, error) {
+	stateLockFilePath := filepath.Join(b.Path, stateLockFileName)
+	res, err := os.ReadFile(stateLockFilePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("read lock: %w", err)
 	}
-	err = os.WriteFile(stateLockFilePath, []byte{}, os.ModePerm)
-	return err
+	return string(res), true, nil
 }
 
 func (b *Backend) UnlockState() error {
 	stateLockFilePath := filepath.Join(b.Path, stateLockFileName)
 	log.Debugf("Unlocking local state. Path: '%v'", stateLockFilePath)
-	return os.Remove(stateLockFilePath)
+	err := os.Remove(stateLockFilePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unlock state: %w", err)
+	}
+	return nil
 }
 
 func (b *Backend) WriteState(stateData string) error {
@@ -33,8 +61,33 @@ func (b *Backend) WriteState(stateData string) error {
 	stateFilePath := filepath.Join(b.Path, stateFileName)
 	log.Debugf("Updating local state. Project: '%v', path: '%v'", b.ProjectPtr.Name(), stateFilePath)
 
-	err := os.WriteFile(stateFilePath, []byte(stateData), os.ModePerm)
-	return err
+	return b.writeFileAtomic(stateFilePath, []byte(stateData))
+}
+
+// writeFileAtomic writes the file through the temporary file in the same dir, so the file is never left
+// truncated if cdev is killed during the write. The temporary file is created with 0600 permissions, which
+// also fixes the wide permissions of the state files created by older cdev versions.
+func (b *Backend) writeFileAtomic(fileName string, data []byte) error {
+	f, err := os.CreateTemp(b.Path, filepath.Base(fileName)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("write %v: %w", fileName, err)
+	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName)
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %v: %w", fileName, err)
+	}
+	if err = os.Rename(tmpName, fileName); err != nil {
+		return fmt.Errorf("write %v: %w", fileName, err)
+	}
+	return nil
 }
 
 func (b *Backend) ReadState() (string, error) {
@@ -45,3 +98,46 @@ func (b *Backend) ReadState() (string, error) {
 	}
 	return string(res), nil
 }
+
+func (b *Backend) ReadStateHistory() (string, error) {
+	res, err := os.ReadFile(filepath.Join(b.Path, stateHistoryFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("read state history: %w", err)
+	}
+	return string(res), nil
+}
+
+func (b *Backend) WriteStateHistory(history string) error {
+	return b.writeFileAtomic(filepath.Join(b.Path, stateHistoryFileName), []byte(history))
+}
+
+// stateVersionFilePath returns the path of the rotated state file.
+func (b *Backend) stateVersionFilePath(version int) string {
+	return filepath.Join(b.Path, fmt.Sprintf("%s.%d", stateFileName, version))
+}
+
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	res, err := os.ReadFile(b.stateVersionFilePath(version))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("read state version %v: %w", version, project.ErrStateVersionNotFound)
+		}
+		return "", fmt.Errorf("read state version %v: %w", version, err)
+	}
+	return string(res), nil
+}
+
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	return b.writeFileAtomic(b.stateVersionFilePath(version), []byte(stateData))
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	err := os.Remove(b.stateVersionFilePath(version))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete state version %v: %w", version, err)
+	}
+	return nil
+}
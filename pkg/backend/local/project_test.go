@@ -0,0 +1,53 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return &Backend{
+			name:       "test",
+			ProjectPtr: project.NewEmptyProject(),
+			Path:       dir,
+		}
+	})
+}
+
+func TestWriteStateReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, stateFileName)
+	if err := os.WriteFile(stateFile, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := &Backend{name: "test", ProjectPtr: project.NewEmptyProject(), Path: dir}
+	if err := b.WriteState("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteStateVersion(1, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteStateHistory("history"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected state file mode 0600, got %v", info.Mode().Perm())
+	}
+	if data, _ := b.ReadState(); data != "new" {
+		t.Errorf("unexpected state: %v", data)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmp-*"))
+	if len(files) > 0 {
+		t.Errorf("temporary files were not removed: %v", files)
+	}
+}
@@ -11,8 +11,8 @@ import (
 // Backend - describe s3 backend for interface package.backend.
 type Backend struct {
 	name       string
-	ProjectPtr *project.Project
-	Path       string `yaml:"path"`
+	ProjectPtr *project.Project `yaml:"-"`
+	Path       string           `yaml:"path"`
 }
 
 // Name return name.
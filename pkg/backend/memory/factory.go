@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Factory factory for memory backends.
+type Factory struct{}
+
+// New creates the new memory backend.
+func (f *Factory) New(cnf []byte, name string, p *project.Project) (project.Backend, error) {
+	bk := Backend{
+		name:       name,
+		ProjectPtr: p,
+		storage:    newStorage(),
+	}
+	err := yaml.Unmarshal(cnf, &bk)
+	if err != nil {
+		return nil, utils.ResolveYamlError(cnf, err)
+	}
+	if bk.StateFile != "" {
+		if !utils.IsAbsolutePath(bk.StateFile) {
+			bk.StateFile = filepath.Join(config.Global.ProjectConfigsPath, bk.StateFile)
+		}
+		data, err := os.ReadFile(bk.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("memory backend: read initial state: %w", err)
+		}
+		bk.storage.state = string(data)
+	}
+	defaultPath := bk.Path == ""
+	if defaultPath {
+		// The terraform states are useless without the cdev state, which is lost on exit. The default dir
+		// is the same for every run and is cleared on the first use, so the states do not pile up.
+		bk.Path = filepath.Join(config.Global.WorkDir, "memory-backend", name)
+	} else if !utils.IsAbsolutePath(bk.Path) {
+		bk.Path = filepath.Join(config.Global.ProjectConfigsPath, bk.Path)
+	}
+	if env := p.Env(); env != "" {
+		bk.Path = filepath.Join(bk.Path, env)
+	}
+	if defaultPath {
+		bk.cleanPath = true
+		return &bk, nil
+	}
+	log.Debugf("Creating memory backend terraform states dir: %v", bk.Path)
+	return &bk, os.MkdirAll(bk.Path, os.ModePerm)
+}
+
+// CheckSpec checks the backend spec without reading the state file.
+func (f *Factory) CheckSpec(cnf []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(cnf, &bk); err != nil {
+		return fmt.Errorf("memory backend: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	log.Debug("Registering backend provider memory..")
+	if err := project.RegisterBackendFactory(&Factory{}, "memory"); err != nil {
+		log.Trace("Can't register backend provider memory.")
+	}
+}
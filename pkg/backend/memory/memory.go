@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// storage keeps the cdev state in process memory. It is lost when cdev exits.
+type storage struct {
+	mux      sync.Mutex
+	state    string
+	lockInfo string
+	locked   bool
+	history  string
+	versions map[int]string
+}
+
+func newStorage() *storage {
+	return &storage{versions: map[int]string{}}
+}
+
+// Backend - describe memory backend for interface package.backend. The cdev state is kept in memory,
+// optionally seeded from the state file. Terraform units use the local backend in the Path dir.
+type Backend struct {
+	name       string           `yaml:"-"`
+	storage    *storage         `yaml:"-"`
+	ProjectPtr *project.Project `yaml:"-"`
+	// cleanPath the Path is the default dir, it is cleared and created on the first use.
+	cleanPath bool
+	pathOnce  sync.Once
+	pathErr   error
+
+	StateFile string `yaml:"state_file,omitempty"`
+	Path      string `yaml:"path,omitempty"`
+}
+
+// Name return name.
+func (b *Backend) Name() string {
+	return b.name
+}
+
+// Provider return name.
+func (b *Backend) Provider() string {
+	return "memory"
+}
+
+// GetBackendBytes generate terraform backend config.
+func (b *Backend) GetBackendBytes(stackName, unitName string) ([]byte, error) {
+	f, err := b.GetBackendHCL(stackName, unitName)
+	if err != nil {
+		return nil, err
+	}
+	return f.Bytes(), nil
+}
+
+// GetBackendHCL generate terraform backend config.
+func (b *Backend) GetBackendHCL(stackName, unitName string) (*hclwrite.File, error) {
+	f := hclwrite.NewEmptyFile()
+	rootBody := f.Body()
+	terraformBlock := rootBody.AppendNewBlock("terraform", []string{})
+	backendBlock := terraformBlock.Body().AppendNewBlock("backend", []string{"local"})
+	backendBody := backendBlock.Body()
+	statePath, err := b.unitStatePath(stackName, unitName)
+	if err != nil {
+		return nil, err
+	}
+	backendBody.SetAttributeValue("path", cty.StringVal(statePath))
+	return f, nil
+}
+
+// GetRemoteStateHCL generate terraform remote state for this backend.
+func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error) {
+	statePath, err := b.unitStatePath(stackName, unitName)
+	if err != nil {
+		return nil, err
+	}
+	f := hclwrite.NewEmptyFile()
+	rootBody := f.Body()
+	dataBlock := rootBody.AppendNewBlock("data", []string{"terraform_remote_state", fmt.Sprintf("%s-%s", stackName, unitName)})
+	dataBody := dataBlock.Body()
+	dataBody.SetAttributeValue("backend", cty.StringVal("local"))
+	dataBody.SetAttributeValue("config", cty.MapVal(map[string]cty.Value{
+		"path": cty.StringVal(statePath),
+	}))
+	return f.Bytes(), nil
+}
+
+func (b *Backend) unitStatePath(stackName, unitName string) (string, error) {
+	b.pathOnce.Do(func() {
+		if !b.cleanPath {
+			return
+		}
+		log.Debugf("Creating memory backend terraform states dir: %v", b.Path)
+		if err := os.RemoveAll(b.Path); err != nil {
+			b.pathErr = err
+			return
+		}
+		b.pathErr = os.MkdirAll(b.Path, os.ModePerm)
+	})
+	if b.pathErr != nil {
+		return "", fmt.Errorf("memory backend: create terraform states dir: %w", b.pathErr)
+	}
+	return filepath.Join(b.Path, fmt.Sprintf("%s.%s.tfstate", stackName, unitName)), nil
+}
+
+func (b *Backend) LockState(lockInfo string) error {
+	log.Debugf("Locking memory state. Project: '%v'", b.ProjectPtr.Name())
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	if b.storage.locked {
+		return fmt.Errorf("lock state: %w", project.ErrStateLocked)
+	}
+	b.storage.lockInfo = lockInfo
+	b.storage.locked = true
+	return nil
+}
+
+func (b *Backend) ReadLock() (string, bool, error) {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	return b.storage.lockInfo, b.storage.locked, nil
+}
+
+func (b *Backend) UnlockState() error {
+	log.Debugf("Unlocking memory state. Project: '%v'", b.ProjectPtr.Name())
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	b.storage.lockInfo = ""
+	b.storage.locked = false
+	return nil
+}
+
+func (b *Backend) WriteState(stateData string) error {
+	log.Debugf("Updating memory state. Project: '%v'", b.ProjectPtr.Name())
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	b.storage.state = stateData
+	return nil
+}
+
+func (b *Backend) ReadState() (string, error) {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	return b.storage.state, nil
+}
+
+func (b *Backend) ReadStateHistory() (string, error) {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	return b.storage.history, nil
+}
+
+func (b *Backend) WriteStateHistory(history string) error {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	b.storage.history = history
+	return nil
+}
+
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	data, exists := b.storage.versions[version]
+	if !exists {
+		return "", fmt.Errorf("read state version %v: %w", version, project.ErrStateVersionNotFound)
+	}
+	return data, nil
+}
+
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	b.storage.versions[version] = stateData
+	return nil
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	b.storage.mux.Lock()
+	defer b.storage.mux.Unlock()
+	delete(b.storage.versions, version)
+	return nil
+}
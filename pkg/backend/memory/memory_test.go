@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+func TestConformance(t *testing.T) {
+	st := newStorage()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return &Backend{
+			name:       "test",
+			ProjectPtr: project.NewEmptyProject(),
+			storage:    st,
+			Path:       t.TempDir(),
+		}
+	})
+}
+
+func TestInitialState(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(stateFile, []byte(`{"version":"seed"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cnf := fmt.Sprintf("state_file: %s\npath: %s\n", stateFile, filepath.Join(dir, "tf"))
+	b, err := (&Factory{}).New([]byte(cnf), "test", project.NewEmptyProject())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, err := b.ReadState(); err != nil || state != `{"version":"seed"}` {
+		t.Fatalf("read initial state: got %q, %v", state, err)
+	}
+	if err := b.WriteState(`{"version":"new"}`); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(stateFile); string(data) != `{"version":"seed"}` {
+		t.Fatalf("initial state file was changed: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tf")); err != nil {
+		t.Fatalf("terraform states dir was not created: %v", err)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	workDir := config.Global.WorkDir
+	config.Global.WorkDir = t.TempDir()
+	defer func() { config.Global.WorkDir = workDir }()
+
+	statesDir := filepath.Join(config.Global.WorkDir, "memory-backend", "test")
+	// The dir left by the previous run.
+	if err := os.MkdirAll(statesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(statesDir, "old.unit.tfstate"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := (&Factory{}).New([]byte("{}"), "test", project.NewEmptyProject())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(statesDir, "old.unit.tfstate")); err != nil {
+		t.Fatalf("the states dir should not be touched before the first use: %v", err)
+	}
+	data, err := b.GetBackendBytes("st", "unit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), filepath.Join(statesDir, "st.unit.tfstate")) {
+		t.Errorf("unexpected backend config: %s", data)
+	}
+	files, err := os.ReadDir(statesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected the states dir to be cleared, got %v files", len(files))
+	}
+}
@@ -1,6 +1,8 @@
 package s3
 
 import (
+	"fmt"
+
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/utils"
@@ -29,6 +31,18 @@ func (f *Factory) New(config []byte, name string, p *project.Project) (project.B
 	return &bk, bk.Configure()
 }
 
+// CheckSpec checks the backend spec without creating the s3 client.
+func (f *Factory) CheckSpec(config []byte) error {
+	bk := Backend{}
+	if err := utils.UnmarshalStrict(config, &bk); err != nil {
+		return fmt.Errorf("s3 backend: %w", err)
+	}
+	if bk.Bucket == "" {
+		return fmt.Errorf("s3 backend: 'bucket' is required")
+	}
+	return nil
+}
+
 func init() {
 	log.Debug("Registering backend provider s3..")
 	if err := project.RegisterBackendFactory(&Factory{}, "s3"); err != nil {
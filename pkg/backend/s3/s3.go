@@ -13,6 +13,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	awsbase "github.com/hashicorp/aws-sdk-go-base/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/shalb/cluster.dev/pkg/config"
@@ -22,6 +24,9 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// defaultWorkspacesPrefix default key prefix of the environment-scoped states, the same as in terraform.
+const defaultWorkspacesPrefix = "env:"
+
 // Backend - describe s3 backend for interface package.backend.
 type Backend struct {
 	name     string     `yaml:"-"`
@@ -52,6 +57,7 @@ type Backend struct {
 	RoleArn                     string            `yaml:"role_arn,omitempty"`
 	SessionName                 string            `yaml:"session_name,omitempty"`
 
+	// Workspaces the key prefix of the environment-scoped states, the same as terraform 'workspace_key_prefix'.
 	Workspaces string `yaml:"workspaces,omitempty"`
 
 	ProjectPtr *project.Project       `yaml:"-"`
@@ -165,7 +171,7 @@ func (b *Backend) GetBackendHCL(stackName, unitName string) (*hclwrite.File, err
 	terraformBlock := rootBody.AppendNewBlock("terraform", []string{})
 	backendBlock := terraformBlock.Body().AppendNewBlock("backend", []string{"s3"})
 	backendBody := backendBlock.Body()
-	backendBody.SetAttributeValue("key", cty.StringVal(fmt.Sprintf("%s/%s.state", stackName, unitName)))
+	backendBody.SetAttributeValue("key", cty.StringVal(b.unitStateKey(stackName, unitName)))
 	bkMap, err := getBackendMap(*b)
 	if err != nil {
 		return nil, err
@@ -185,6 +191,8 @@ func getBackendMap(in Backend) (res map[string]cty.Value, err error) {
 	resMap := map[string]interface{}{}
 	err = yaml.Unmarshal(tmpData, &resMap)
 	err = utils.ResolveYamlError(tmpData, err)
+	// Not a terraform option, the environment prefix is added to the state key.
+	delete(resMap, "workspaces")
 	res = map[string]cty.Value{}
 	for k, v := range resMap {
 		res[k] = cty.StringVal(fmt.Sprintf("%v", v))
@@ -204,110 +212,203 @@ func (b *Backend) GetRemoteStateHCL(stackName, unitName string) ([]byte, error)
 	if err != nil {
 		return nil, fmt.Errorf("generate s3 remote state: %w", err)
 	}
-	config["key"] = cty.StringVal(fmt.Sprintf("%s/%s.state", stackName, unitName))
+	config["key"] = cty.StringVal(b.unitStateKey(stackName, unitName))
 
 	dataBody.SetAttributeValue("config", cty.MapVal(config))
 	return f.Bytes(), nil
 }
 
-func (b *Backend) ReadState() (string, error) {
+// getObject reads the object from the bucket. Returns false if the object does not exist.
+func (b *Backend) getObject(key string) (string, bool, error) {
 	result, err := b.s3Client.GetObject(
 		context.TODO(),
 		&s3.GetObjectInput{
 			Bucket: &b.Bucket,
-			Key:    b.stateKey(),
+			Key:    &key,
 		},
 	)
 	if err != nil {
 		var bne *types.NoSuchKey
 		if errors.As(err, &bne) {
-			return "", nil
+			return "", false, nil
 		}
-		return "", fmt.Errorf("get state from s3 bucket: %v", err.Error())
+		return "", false, err
 	}
 	defer result.Body.Close()
 	body, err := io.ReadAll(result.Body)
 	if err != nil {
-		return "", fmt.Errorf("get state from s3 bucket: read file body: %v", err.Error())
+		return "", false, fmt.Errorf("read file body: %w", err)
 	}
-	return string(body), nil
+	return string(body), true, nil
 }
 
-func (b *Backend) WriteState(stateData string) error {
-	ioBody := strings.NewReader(stateData)
+func (b *Backend) putObject(key, data string) error {
 	_, err := b.s3Client.PutObject(
 		context.TODO(),
 		&s3.PutObjectInput{
 			Bucket: &b.Bucket,
-			Key:    b.stateKey(),
-			Body:   ioBody,
+			Key:    &key,
+			Body:   strings.NewReader(data),
+		},
+	)
+	return err
+}
+
+func (b *Backend) deleteObject(key string) error {
+	_, err := b.s3Client.DeleteObject(
+		context.TODO(),
+		&s3.DeleteObjectInput{
+			Bucket: &b.Bucket,
+			Key:    &key,
 		},
 	)
+	return err
+}
+
+func (b *Backend) ReadState() (string, error) {
+	state, _, err := b.getObject(*b.stateKey())
+	if err != nil {
+		return "", fmt.Errorf("get state from s3 bucket: %w", err)
+	}
+	return state, nil
+}
+
+func (b *Backend) WriteState(stateData string) error {
+	err := b.putObject(*b.stateKey(), stateData)
+	if err != nil {
+		return fmt.Errorf("write state to s3 bucket: %w", err)
+	}
+	return nil
+}
+
+func (b *Backend) ReadStateHistory() (string, error) {
+	history, _, err := b.getObject(b.historyKey())
+	if err != nil {
+		return "", fmt.Errorf("get state history from s3 bucket: %w", err)
+	}
+	return history, nil
+}
+
+func (b *Backend) WriteStateHistory(history string) error {
+	err := b.putObject(b.historyKey(), history)
+	if err != nil {
+		return fmt.Errorf("write state history to s3 bucket: %w", err)
+	}
+	return nil
+}
+
+func (b *Backend) ReadStateVersion(version int) (string, error) {
+	state, exists, err := b.getObject(b.stateVersionKey(version))
+	if err != nil {
+		return "", fmt.Errorf("get state version %v from s3 bucket: %w", version, err)
+	}
+	if !exists {
+		return "", fmt.Errorf("get state version %v from s3 bucket: %w", version, project.ErrStateVersionNotFound)
+	}
+	return state, nil
+}
+
+func (b *Backend) WriteStateVersion(version int, stateData string) error {
+	err := b.putObject(b.stateVersionKey(version), stateData)
+	if err != nil {
+		return fmt.Errorf("write state version %v to s3 bucket: %w", version, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteStateVersion(version int) error {
+	err := b.deleteObject(b.stateVersionKey(version))
 	if err != nil {
-		return fmt.Errorf("write state to s3 bucket: %v", err.Error())
+		return fmt.Errorf("delete state version %v from s3 bucket: %w", version, err)
 	}
 	return nil
 }
 
+// envKey returns the key in the active environment. The environment states are stored the same way as
+// terraform workspaces: '<workspaces>/<env>/<key>'.
+func (b *Backend) envKey(key string) string {
+	env := b.ProjectPtr.Env()
+	if env == "" {
+		return key
+	}
+	prefix := b.Workspaces
+	if prefix == "" {
+		prefix = defaultWorkspacesPrefix
+	}
+	return fmt.Sprintf("%s/%s/%s", prefix, env, key)
+}
+
+func (b *Backend) unitStateKey(stackName, unitName string) string {
+	return b.envKey(fmt.Sprintf("%s/%s.state", stackName, unitName))
+}
+
 func (b *Backend) stateKey() *string {
-	res := fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name())
+	res := b.envKey(fmt.Sprintf("cdev.%s.state", b.ProjectPtr.Name()))
 	return &res
 }
 
+func (b *Backend) stateVersionKey(version int) string {
+	return fmt.Sprintf("%s.%d", *b.stateKey(), version)
+}
+
+func (b *Backend) historyKey() string {
+	return b.envKey(fmt.Sprintf("cdev.%s.history", b.ProjectPtr.Name()))
+}
+
 func (b *Backend) lockKey() *string {
-	res := fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name())
+	res := b.envKey(fmt.Sprintf("cdev.%s.lock", b.ProjectPtr.Name()))
 	return &res
 }
 
-func (b *Backend) LockState() error {
-	// Check if state file exists.
+func (b *Backend) LockState(lockInfo string) error {
 	log.Debugf("Locking s3 state. Project: '%v', bucket: '%v'", b.ProjectPtr.Name(), b.Bucket)
-	result, err := b.s3Client.GetObject(
+	// Conditional write: S3 creates the lock object only if it does not exist yet,
+	// so only one of the concurrent lockers succeeds.
+	_, err := b.s3Client.PutObject(
 		context.TODO(),
-		&s3.GetObjectInput{
+		&s3.PutObjectInput{
 			Bucket: &b.Bucket,
 			Key:    b.lockKey(),
+			Body:   strings.NewReader(lockInfo),
 		},
+		s3.WithAPIOptions(smithyhttp.AddHeaderValue("If-None-Match", "*")),
 	)
 	if err != nil {
-		var bne *types.NoSuchKey
-		if !errors.As(err, &bne) {
-			return fmt.Errorf("lock state: get lock file: %v", err.Error())
+		if isPreconditionFailed(err) {
+			return fmt.Errorf("lock state: %w, lock file '%v' found in bucket '%v'. Use command 'cdev state unlock' to force unlock (unsafe)", project.ErrStateLocked, *b.lockKey(), b.Bucket)
 		}
-	} else {
-		defer result.Body.Close()
+		return fmt.Errorf("lock state: write lock file to s3 bucket: %w", err)
 	}
-	if err == nil {
-		return fmt.Errorf("lock state file found, the state is locked. Use command 'cdev state unlock' to force unlock (unsafe)")
+	return nil
+}
+
+// isPreconditionFailed checks if the conditional write was rejected because the object already exists.
+// S3 returns 409 ConditionalRequestConflict if the object is being created concurrently.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
-	// Lock file not found, process lock
-	ioBody := strings.NewReader(b.ProjectPtr.SessionId)
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
 
-	_, err = b.s3Client.PutObject(
-		context.TODO(),
-		&s3.PutObjectInput{
-			Bucket: &b.Bucket,
-			Key:    b.lockKey(),
-			Body:   ioBody,
-		},
-	)
+func (b *Backend) ReadLock() (string, bool, error) {
+	lockInfo, locked, err := b.getObject(*b.lockKey())
 	if err != nil {
-		return fmt.Errorf("write state to s3 bucket: %v", err.Error())
+		return "", false, fmt.Errorf("read lock file from s3 bucket: %w", err)
 	}
-	return nil
+	return lockInfo, locked, nil
 }
 
 func (b *Backend) UnlockState() error {
 	log.Debugf("Unlocking s3 state. Project: '%v', bucket: '%v'", b.ProjectPtr.Name(), b.Bucket)
-	_, err := b.s3Client.DeleteObject(
-		context.TODO(),
-		&s3.DeleteObjectInput{
-			Bucket: &b.Bucket,
-			Key:    b.lockKey(),
-		},
-	)
+	err := b.deleteObject(*b.lockKey())
 	if err != nil {
-		return fmt.Errorf("delete state lock from s3 bucket: %v", err.Error())
+		return fmt.Errorf("delete state lock from s3 bucket: %w", err)
 	}
 	return nil
 }
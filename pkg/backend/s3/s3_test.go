@@ -0,0 +1,93 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/shalb/cluster.dev/pkg/backend/backendtest"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+// fakeS3 in-process S3 API (path style) which supports the If-None-Match conditional writes.
+type fakeS3 struct {
+	mux     sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, "BadRequest")
+			return
+		}
+		if _, exists := f.objects[key]; exists && r.Header.Get("If-None-Match") == "*" {
+			f.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		f.objects[key] = body
+	case http.MethodGet:
+		data, exists := f.objects[key]
+		if !exists {
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer srv.Close()
+	p := project.NewEmptyProject()
+	backendtest.RunConformance(t, func(t *testing.T) project.Backend {
+		return &Backend{
+			name:       "test",
+			Bucket:     "test-bucket",
+			ProjectPtr: p,
+			s3Client: s3.New(s3.Options{
+				Region:       "us-east-1",
+				BaseEndpoint: aws.String(srv.URL),
+				UsePathStyle: true,
+				Credentials:  aws.AnonymousCredentials{},
+			}),
+		}
+	})
+}
+
+func TestCheckSpec(t *testing.T) {
+	f := &Factory{}
+	cases := map[string]bool{
+		"bucket: b\nregion: eu-central-1\n": true,
+		"bucket: b\nworkspaces: envs\n":     true,
+		"region: eu-central-1\n":            false,
+		"bucket: b\nbukcet: c\n":            false,
+	}
+	for spec, valid := range cases {
+		err := f.CheckSpec([]byte(spec))
+		if (err == nil) != valid {
+			t.Errorf("spec %q: unexpected result: %v", spec, err)
+		}
+	}
+}
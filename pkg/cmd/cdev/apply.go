@@ -10,11 +10,23 @@ import (
 
 // planCmd represents the plan command
 var applyCmd = &cobra.Command{
-	Use:           "apply",
+	Use:           "apply [planfile]",
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	Short:         "Deploys or updates infrastructure according to project configuration",
+	Args:          cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		var planFile *project.PlanFile
+		if len(args) == 1 {
+			var err error
+			planFile, err = project.ReadPlanFile(args[0])
+			if err != nil {
+				return NewCmdErr(nil, "apply", err)
+			}
+			if err = planFile.SetGlobalConfig(); err != nil {
+				return NewCmdErr(nil, "apply", err)
+			}
+		}
 		project, err := project.LoadProjectFull()
 		if utils.GetEnv("CDEV_COLLECT_USAGE_STATS", "true") != "false" {
 			log.Infof("Sending usage statistic. To disable statistics collection, export the CDEV_COLLECT_USAGE_STATS=false environment variable")
@@ -22,12 +34,16 @@ var applyCmd = &cobra.Command{
 		if err != nil {
 			return NewCmdErr(project, "apply", err)
 		}
-		err = project.LockState()
-		defer project.UnLockState()
+		err = project.LockState(cmd.Context())
 		if err != nil {
 			return NewCmdErr(project, "apply", err)
 		}
-		err = project.Apply()
+		defer project.UnLockState()
+		if planFile != nil {
+			err = project.ApplyPlan(cmd.Context(), planFile)
+		} else {
+			err = project.Apply(cmd.Context())
+		}
 		if err != nil {
 			return NewCmdErr(project, "apply", err)
 		}
@@ -44,5 +60,6 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().BoolVar(&config.Global.IgnoreState, "ignore-state", false, "Apply even if the state has not changed.")
 	applyCmd.Flags().BoolVar(&config.Global.Force, "force", false, "Skip interactive approval.")
-	applyCmd.Flags().StringArrayVarP(&config.Global.Targets, "target", "t", []string{}, "Units and stack that will be applied. All others will not apply.")
+	applyCmd.Flags().StringArrayVarP(&config.Global.Targets, "target", "t", []string{}, "Units and stacks to apply. Their dependencies are applied too, all others are skipped.")
+	applyCmd.Flags().BoolVar(&config.Global.KeepGoing, "keep-going", false, "Do not stop on unit failure. Skip only units depending on failed ones and continue with others.")
 }
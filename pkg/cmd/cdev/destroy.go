@@ -17,12 +17,12 @@ var destroyCmd = &cobra.Command{
 		if err != nil {
 			return NewCmdErr(project, "destroy", err)
 		}
-		err = project.LockState()
-		defer project.UnLockState()
+		err = project.LockState(cmd.Context())
 		if err != nil {
 			return NewCmdErr(project, "destroy", err)
 		}
-		err = project.Destroy()
+		defer project.UnLockState()
+		err = project.Destroy(cmd.Context())
 		if err != nil {
 			return NewCmdErr(project, "destroy", err)
 		}
@@ -35,5 +35,6 @@ func init() {
 	rootCmd.AddCommand(destroyCmd)
 	destroyCmd.Flags().BoolVar(&config.Global.IgnoreState, "ignore-state", false, "Destroy current configuration and ignore state.")
 	destroyCmd.Flags().BoolVar(&config.Global.Force, "force", false, "Skip interactive approval.")
-	destroyCmd.Flags().StringArrayVarP(&config.Global.Targets, "target", "t", []string{}, "Units and stack that will be destroyed. All others will not destroy.")
+	destroyCmd.Flags().StringArrayVarP(&config.Global.Targets, "target", "t", []string{}, "Units and stacks to destroy. Units depending on them are destroyed too, all others are skipped.")
+	destroyCmd.Flags().BoolVar(&config.Global.KeepGoing, "keep-going", false, "Do not stop on unit failure. Skip only units which failed ones depend on and continue with others.")
 }
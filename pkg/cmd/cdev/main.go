@@ -1,11 +1,16 @@
 package cdev
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/config"
 	"github.com/shalb/cluster.dev/pkg/profiler"
+	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +19,9 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "cdev",
 	Short: "See https://docs.cluster.dev/ for details.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.Global.Command = cmd.CommandPath()
+	},
 }
 
 func init() {
@@ -22,6 +30,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&config.Global.LogLevel, "log-level", "l", "info", "Set the logging level ('debug'|'info'|'warn'|'error'|'fatal')")
 	rootCmd.PersistentFlags().BoolVar(&config.Global.UseCache, "cache", false, "Use previously cached build directory")
 	rootCmd.PersistentFlags().IntVar(&config.Global.MaxParallel, "parallelism", 3, "Max parallel threads for units applying")
+	rootCmd.PersistentFlags().DurationVar(&config.Global.UnitTimeout, "timeout", 0, "Default timeout for each unit operation (init, plan, apply, destroy), e.g. '30m'. Zero means no timeout")
+	rootCmd.PersistentFlags().DurationVar(&config.Global.LockTimeout, "lock-timeout", 0, "Wait for the state lock up to this duration, e.g. '5m', instead of failing immediately")
+	rootCmd.PersistentFlags().DurationVar(&config.Global.LockTTL, "lock-ttl", 0, "Mark the state lock as stale after this duration, other processes warn about it. Zero means the lock never expires")
+	rootCmd.PersistentFlags().StringVar(&config.Global.Env, "env", "", "Environment: apply the overlay from 'envs/<env>' directory and use the environment-scoped states")
+	rootCmd.PersistentFlags().StringArrayVar(&config.Global.Vars, "var", []string{}, "Override the stack variable, e.g. 'my-stack.image.tag=v1.2.0'. Use 'project' instead of the stack name to override the project variable")
+	rootCmd.PersistentFlags().StringArrayVar(&config.Global.VarFiles, "var-file", []string{}, "Override the variables with values from the yaml file. Top level keys are the stack names or 'project'")
 	rootCmd.PersistentFlags().BoolVar(&config.Global.TraceLog, "trace", false, "Print functions trace info in logs")
 	rootCmd.PersistentFlags().BoolVar(&config.Global.NoColor, "no-color", false, "Turn off colored output")
 	rootCmd.PersistentFlags().BoolP("version", "v", false, "Print client version")
@@ -32,7 +46,9 @@ func init() {
 func Run() {
 	profiler.Global.MainTimeLine().Start()
 
-	err := rootCmd.Execute()
+	ctx, unitsCtx, stop := interruptContext()
+	defer stop()
+	err := rootCmd.ExecuteContext(project.WithUnitsContext(ctx, unitsCtx))
 	extendedErr, ok := err.(*CmdErrExtended)
 	if !ok {
 		log.Debugf("Usage stats are unavailable in current command. Ignore.")
@@ -67,9 +83,51 @@ func Run() {
 		st.ProjectID = "null"
 		st.BackendType = "null"
 	}
-	exporter := utils.StatsExporter{}
-	_ = exporter.PushStats(st)
+	if !config.Global.Offline {
+		exporter := utils.StatsExporter{}
+		_ = exporter.PushStats(st)
+	}
 	if extendedErr.Err != nil {
 		log.Fatalf("Fatal error: %v", err.Error())
 	}
+	if extendedErr.ExitCode != 0 {
+		os.Exit(extendedErr.ExitCode)
+	}
+}
+
+// interruptContext returns the context for two-stage interrupt handling. The first SIGINT/SIGTERM
+// cancels the context: cdev stops scheduling new units, waits for the running ones and saves the state.
+// The second signal cancels unitsCtx and kills the running units.
+func interruptContext() (ctx, unitsCtx context.Context, stop func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	unitsCtx, killUnits := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigChan:
+		case <-done:
+			return
+		}
+		fmt.Println()
+		log.Warnf("Interrupted. Waiting for running units to finish, press Ctrl+C again to kill them...")
+		cancel()
+		select {
+		case <-sigChan:
+		case <-done:
+			return
+		}
+		log.Warnf("Killing running units...")
+		killUnits()
+		// Restore the default signals handling, so the next signal terminates cdev immediately.
+		signal.Stop(sigChan)
+	}()
+	stop = func() {
+		signal.Stop(sigChan)
+		close(done)
+		cancel()
+		killUnits()
+	}
+	return ctx, unitsCtx, stop
 }
@@ -0,0 +1,49 @@
+package cdev
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitDone returns true if the context is done in a second.
+func waitDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestInterruptContext(t *testing.T) {
+	ctx, unitsCtx, stop := interruptContext()
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	if !waitDone(ctx) {
+		t.Fatal("the context should be cancelled by the first interrupt")
+	}
+	if unitsCtx.Err() != nil {
+		t.Fatal("the units context should not be cancelled by the first interrupt")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if !waitDone(unitsCtx) {
+		t.Fatal("the units context should be cancelled by the second interrupt")
+	}
+}
+
+func TestInterruptContextStop(t *testing.T) {
+	ctx, unitsCtx, stop := interruptContext()
+	stop()
+	if ctx.Err() == nil || unitsCtx.Err() == nil {
+		t.Error("the contexts should be cancelled by stop")
+	}
+}
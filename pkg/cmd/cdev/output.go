@@ -4,6 +4,7 @@ import (
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/config"
 	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/spf13/cobra"
 )
 
@@ -12,15 +13,18 @@ var outputCmd = &cobra.Command{
 	Use:   "output",
 	Short: "Show project outputs",
 	Run: func(cmd *cobra.Command, args []string) {
+		if config.Global.ShowSensitive {
+			sensitive.Disable()
+		}
 		project, err := project.LoadProjectFull()
 		if err != nil {
 			log.Fatalf("Fatal error: outputs: %v", err.Error())
 		}
-		err = project.LockState()
-		defer project.UnLockState()
+		err = project.LockState(cmd.Context())
 		if err != nil {
 			log.Fatalf("Fatal error: outputs: lock state: %v", err.Error())
 		}
+		defer project.UnLockState()
 		err = project.OwnState.PrintOutputs()
 		if err != nil {
 			log.Fatalf("Fatal error: outputs: print %v", err.Error())
@@ -30,5 +34,6 @@ var outputCmd = &cobra.Command{
 
 func init() {
 	outputCmd.Flags().BoolVar(&config.Global.OutputJSON, "json", false, "Show outputs in JSON format.")
+	outputCmd.Flags().BoolVar(&config.Global.ShowSensitive, "show-sensitive", false, "Show sensitive values (secrets and sensitive outputs) instead of '***'.")
 	rootCmd.AddCommand(outputCmd)
 }
@@ -2,13 +2,17 @@ package cdev
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/logging"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/spf13/cobra"
 )
 
+var planOutFile string
+
 // planCmd represents the plan command
 var planCmd = &cobra.Command{
 	Use:           "plan",
@@ -16,16 +20,30 @@ var planCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if config.Global.OutputJSON {
+			// Keep stdout clean for the plan document.
+			logging.SetOutput(os.Stderr)
+		}
 		project, err := project.LoadProjectFull()
 		if err != nil {
 			return NewCmdErr(nil, "plan", fmt.Errorf("load project configuration: %w", err))
 		}
 		log.Info("Planning...")
-		_, err = project.Plan()
+		planGraph, err := project.Plan()
 		if err != nil {
-			return NewCmdErr(project, "plan", fmt.Errorf("build plan: %w", err))
+			err = fmt.Errorf("build plan: %w", err)
+		}
+		if err == nil && config.Global.OutputJSON {
+			err = project.PrintPlanJSON(planGraph)
+		}
+		if err == nil && planOutFile != "" {
+			err = project.SavePlan(planGraph, planOutFile)
+		}
+		res := NewCmdErr(project, "plan", err)
+		if config.Global.DetailedExitCode {
+			res.ExitCode = project.PlanExitCode(planGraph, err)
 		}
-		return NewCmdErr(project, "plan", nil)
+		return res
 	},
 }
 
@@ -33,4 +51,8 @@ func init() {
 	rootCmd.AddCommand(planCmd)
 	// planCmd.Flags().BoolVar(&config.Global.ShowTerraformPlan, "tf-plan", false, "Also show units terraform plan if possible.")
 	planCmd.Flags().BoolVar(&config.Global.IgnoreState, "force", false, "Show plan (if set tf-plan) even if the state has not changed.")
+	planCmd.Flags().StringArrayVarP(&config.Global.Targets, "target", "t", []string{}, "Units and stacks to plan. Only the targets and their dependencies will be planned.")
+	planCmd.Flags().BoolVar(&config.Global.OutputJSON, "json", false, "Print the plan as a JSON document to stdout. Logs are written to stderr.")
+	planCmd.Flags().BoolVar(&config.Global.DetailedExitCode, "detailed-exitcode", false, "Return detailed exit code: 0 - no changes, 1 - error, 2 - there are changes.")
+	planCmd.Flags().StringVarP(&planOutFile, "out", "o", "", "Save the plan to a file, which can be applied later with 'cdev apply <planfile>'.")
 }
@@ -2,10 +2,12 @@ package cdev
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/logging"
 	"github.com/shalb/cluster.dev/pkg/project"
 	"github.com/shalb/cluster.dev/pkg/project/ui"
 	"github.com/spf13/cobra"
@@ -17,11 +19,16 @@ var projectCmd = &cobra.Command{
 	Short: "Manage projects",
 }
 var listAllTemplates bool
+var graphFormat string
+var graphWithPlan bool
 
 func init() {
 	rootCmd.AddCommand(projectCmd)
 	projectCmd.AddCommand(projectLs)
 	projectCmd.AddCommand(projectCreate)
+	projectCmd.AddCommand(projectGraph)
+	projectGraph.Flags().StringVar(&graphFormat, "format", "dot", fmt.Sprintf("Output format (%s)", strings.Join(project.GraphExportFormats, "|")))
+	projectGraph.Flags().BoolVar(&graphWithPlan, "plan", false, "Color units by the planned operation. Reads the project state")
 	projectCreate.Flags().BoolVar(&config.Global.Interactive, "interactive", false, "Use interactive mode for project generation")
 	projectCreate.Flags().BoolVar(&listAllTemplates, "list-templates", false, "Show all available templates for project generation")
 }
@@ -67,3 +74,25 @@ var projectCreate = &cobra.Command{
 		}
 	},
 }
+
+// projectGraph represents the project graph command
+var projectGraph = &cobra.Command{
+	Use:   "graph",
+	Short: "Prints units dependency graph of the current project in dot, mermaid or json format",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Keep stdout clean for the graph document.
+		logging.SetOutput(os.Stderr)
+		if !graphWithPlan {
+			config.Global.IgnoreState = true
+		}
+		p, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: project graph: %v", err.Error())
+		}
+		res, err := p.ExportGraph(graphFormat, graphWithPlan)
+		if err != nil {
+			log.Fatalf("Fatal error: project graph: %v", err.Error())
+		}
+		fmt.Print(res)
+	},
+}
@@ -1,9 +1,12 @@
 package cdev
 
 import (
+	"strconv"
+
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/config"
 	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +26,13 @@ var stateUnlockCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Fatal error: state unlock: %v", err.Error())
 		}
+		info, err := project.ReadLockInfo()
+		if err != nil {
+			log.Fatalf("Fatal error: state unlock: %v", err.Error())
+		}
+		if info != nil {
+			log.Infof("Removing the state lock, locked by: %s", info)
+		}
 		log.Info("Unlocking state...")
 		err = project.UnLockState()
 		if err != nil {
@@ -31,6 +41,132 @@ var stateUnlockCmd = &cobra.Command{
 	},
 }
 
+var stateLockInfoCmd = &cobra.Command{
+	Use:   "lock-info",
+	Short: "Show the state lock holder",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state lock-info: %v", err.Error())
+		}
+		err = project.PrintLockInfo()
+		if err != nil {
+			log.Fatalf("Fatal error: state lock-info: %v", err.Error())
+		}
+	},
+}
+
+var stateHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the state versions history",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state history: %v", err.Error())
+		}
+		err = project.PrintStateHistory()
+		if err != nil {
+			log.Fatalf("Fatal error: state history: %v", err.Error())
+		}
+	},
+}
+
+var stateRollbackCmd = &cobra.Command{
+	Use:   "rollback <version>",
+	Short: "Restore the state version from the history. The infrastructure is not changed, run 'cdev plan' after rollback to see the difference",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Fatal error: state rollback: bad version '%v', see 'cdev state history'", args[0])
+		}
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state rollback: %v", err.Error())
+		}
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state rollback: %v", err.Error())
+		}
+		defer project.UnLockState()
+		err = project.RollbackState(version)
+		if err != nil {
+			project.UnLockState()
+			log.Fatalf("Fatal error: state rollback: %v", err.Error())
+		}
+		log.Infof("The state was rolled back to version %v. Run 'cdev plan' to see the difference with the project configuration", version)
+	},
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show <unit>",
+	Short: "Show the unit state and the unit outputs used by other units. The unit is set as 'stack_name.unit_name'",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		if config.Global.ShowSensitive {
+			sensitive.Disable()
+		}
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state show: %v", err.Error())
+		}
+		err = project.ShowStateUnit(args[0])
+		if err != nil {
+			log.Fatalf("Fatal error: %v", err.Error())
+		}
+	},
+}
+
+var stateRmCmd = &cobra.Command{
+	Use:   "rm <unit> [<unit>...]",
+	Short: "Remove units from the state without destroying them. Units are set as 'stack_name.unit_name'",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state rm: %v", err.Error())
+		}
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state rm: %v", err.Error())
+		}
+		defer project.UnLockState()
+		err = project.RemoveStateUnits(args)
+		if err != nil {
+			project.UnLockState()
+			log.Fatalf("Fatal error: %v", err.Error())
+		}
+	},
+}
+
+var stateMvCmd = &cobra.Command{
+	Use:   "mv <old_unit> <new_unit>",
+	Short: "Rename the unit in the state, e.g. after the unit or stack was renamed in the project. Units are set as 'stack_name.unit_name'",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state mv: %v", err.Error())
+		}
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state mv: %v", err.Error())
+		}
+		defer project.UnLockState()
+		err = project.MoveStateUnit(args[0], args[1])
+		if err != nil {
+			project.UnLockState()
+			log.Fatalf("Fatal error: %v", err.Error())
+		}
+	},
+}
+
 // planCmd represents the plan command
 var stateUpdateCmd = &cobra.Command{
 	Use:   "update",
@@ -41,7 +177,10 @@ var stateUpdateCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Fatal error: state update: %v", err.Error())
 		}
-		project.LockState()
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state update: %v", err.Error())
+		}
 		defer project.UnLockState()
 
 		err = project.BackupState()
@@ -67,7 +206,10 @@ var statePullCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Fatal error: state pull: %v", err.Error())
 		}
-		project.LockState()
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state pull: %v", err.Error())
+		}
 		defer project.UnLockState()
 
 		log.Info("Updating state...")
@@ -78,9 +220,40 @@ var statePullCmd = &cobra.Command{
 	},
 }
 
+var statePushCmd = &cobra.Command{
+	Use:   "push <file>",
+	Short: "Uploads the state file to the remote state, e.g. the state repaired after 'cdev state pull'. The current remote state is backed up",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Global.IgnoreState = true
+		project, err := project.LoadProjectFull()
+		if err != nil {
+			log.Fatalf("Fatal error: state push: %v", err.Error())
+		}
+		err = project.LockState(cmd.Context())
+		if err != nil {
+			log.Fatalf("Fatal error: state push: %v", err.Error())
+		}
+		defer project.UnLockState()
+		err = project.PushState(args[0])
+		if err != nil {
+			project.UnLockState()
+			log.Fatalf("Fatal error: state push: %v", err.Error())
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(stateCmd)
 	stateCmd.AddCommand(stateUnlockCmd)
+	stateCmd.AddCommand(stateLockInfoCmd)
+	stateCmd.AddCommand(stateHistoryCmd)
+	stateCmd.AddCommand(stateRollbackCmd)
+	stateShowCmd.Flags().BoolVar(&config.Global.ShowSensitive, "show-sensitive", false, "Show sensitive values (secrets and sensitive outputs) instead of '***'.")
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateRmCmd)
+	stateCmd.AddCommand(stateMvCmd)
 	stateCmd.AddCommand(stateUpdateCmd)
 	stateCmd.AddCommand(statePullCmd)
+	stateCmd.AddCommand(statePushCmd)
 }
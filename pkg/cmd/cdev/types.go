@@ -12,6 +12,7 @@ type CmdErrExtended struct {
 	Command    string
 	Err        error
 	ProjectPtr *project.Project
+	ExitCode   int
 }
 
 func (e *CmdErrExtended) Error() string {
@@ -0,0 +1,34 @@
+package cdev
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:           "validate",
+	Short:         "Check the project manifests, stack templates and units without accessing the backends",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config.Global.Offline = true
+		p, findings := project.Validate()
+		for _, finding := range findings {
+			log.Error(finding)
+		}
+		if len(findings) > 0 {
+			return NewCmdErr(p, "validate", fmt.Errorf("validate: %v problem(s) found", len(findings)))
+		}
+		log.Info("The project is valid.")
+		return NewCmdErr(p, "validate", nil)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/colors"
@@ -16,8 +17,6 @@ var Version string
 // BuildTimestamp - build date from compiller
 var BuildTimestamp string
 
-var Interrupted bool
-
 type SubCmd int
 
 const (
@@ -63,7 +62,21 @@ type ConfSpec struct {
 	Force             bool
 	Interactive       bool
 	OutputJSON        bool
+	ShowSensitive     bool
+	DetailedExitCode  bool
+	KeepGoing         bool
+	UnitTimeout       time.Duration
+	LockTimeout       time.Duration
+	LockTTL           time.Duration
+	Command           string
 	Targets           []string
+	Vars              []string
+	VarFiles          []string
+	Env               string
+	// VarsData the yaml encoded variables overrides saved in the plan file, used instead of Vars and VarFiles.
+	VarsData string
+	// Offline disables the cdev own network requests: the new release check and the usage stats.
+	Offline bool
 }
 
 // Global config for executor.
@@ -105,5 +118,4 @@ func InitConfig() {
 	if Global.MaxParallel == 0 {
 		log.Fatal("Parallelism should be greater then 0.")
 	}
-	Interrupted = false
 }
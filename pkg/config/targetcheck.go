@@ -1,6 +1,9 @@
 package config
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type TargetUnits []string
 
@@ -10,6 +13,28 @@ func NewTargetsChecker(tg []string) *TargetUnits {
 	return &res
 }
 
+// IsEmpty returns true if no targets were set.
+func (t *TargetUnits) IsEmpty() bool {
+	return t == nil || len(*t) == 0
+}
+
+// Validate checks targets format. Target should be 'stack' or 'stack.unit'.
+func (t *TargetUnits) Validate() error {
+	for _, target := range *t {
+		tgSplitted := strings.Split(target, ".")
+		if len(tgSplitted) > 2 {
+			return fmt.Errorf("bad target '%v', use 'stack_name' or 'stack_name.unit_name'", target)
+		}
+		for _, part := range tgSplitted {
+			if part == "" {
+				return fmt.Errorf("bad target '%v', use 'stack_name' or 'stack_name.unit_name'", target)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns true if unit key (stack.unit) matches any of targets.
 func (t *TargetUnits) Check(unitKey string) bool {
 	for _, target := range *t {
 		tgSplitted := strings.Split(target, ".")
@@ -26,7 +51,7 @@ func (t *TargetUnits) Check(unitKey string) bool {
 		}
 		// The target is unit, compare unit name and stack name.
 		if uKeySplitted[0] == tgSplitted[0] && uKeySplitted[1] == tgSplitted[1] {
-			return false
+			return true
 		}
 	}
 	return false
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestTargetUnitsCheck(t *testing.T) {
+	targets := NewTargetsChecker([]string{"infra", "apps.web"})
+	cases := map[string]bool{
+		"infra.vpc":  true,
+		"infra.eks":  true,
+		"apps.web":   true,
+		"apps.api":   false,
+		"other.web":  false,
+		"bad-key":    false,
+		"apps.web.x": false,
+	}
+	for key, expected := range cases {
+		if res := targets.Check(key); res != expected {
+			t.Errorf("Check(%v): expected %v, actual value: %v", key, expected, res)
+		}
+	}
+}
+
+func TestTargetUnitsValidate(t *testing.T) {
+	if err := NewTargetsChecker([]string{"infra", "apps.web"}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, bad := range []string{"a.b.c", "apps.", ".web", ""} {
+		if err := NewTargetsChecker([]string{bad}).Validate(); err == nil {
+			t.Errorf("Expected error for target '%v'", bad)
+		}
+	}
+}
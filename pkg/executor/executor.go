@@ -3,11 +3,11 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"os/signal"
 	"strings"
 	"syscall"
 	"time"
@@ -25,9 +25,14 @@ type ShRunner struct {
 	Timeout           time.Duration
 	LogLabels         []string
 	ShowResultMessage bool
-	Interrupted       bool
 }
 
+// ErrTimeout returned if the command was killed by the runner timeout.
+var ErrTimeout = errors.New("command timeout")
+
+// waitDelay time to wait for the command to stop after SIGTERM, before it is killed.
+const waitDelay = 10 * time.Second
+
 // Env - global list of environment variables.
 var Env []string
 
@@ -47,7 +52,6 @@ func NewExecutor(workingDir string, envVariables ...string) (*ShRunner, error) {
 	}
 	// Create runner.
 	runner := ShRunner{
-		Interrupted:       false,
 		workingDir:        workingDir,
 		Timeout:           0,
 		Env:               envVariables,
@@ -57,63 +61,44 @@ func NewExecutor(workingDir string, envVariables ...string) (*ShRunner, error) {
 	return &runner, nil
 }
 
-func (b *ShRunner) commandExecCommon(outputBuff io.Writer, errBuff io.Writer, command string, args ...string) error {
-	// Prepare command, set outputs, run.
-	if config.Interrupted {
-		return fmt.Errorf("interrupted")
-	}
-	var ctx context.Context
-	var cancel context.CancelFunc
-	if b.Timeout == 0 {
-		ctx = context.Background()
-	} else {
-		ctx, cancel = context.WithTimeout(context.Background(), b.Timeout)
-		defer cancel()
-	}
-
-	cmd := exec.CommandContext(ctx, command, args...)
-	cmd.Stdout = outputBuff
-	cmd.Stderr = errBuff
-
-	if b.workingDir != "" {
-		cmd.Dir = b.workingDir
-	}
-	// Add global environments.
-	envTmp := append(os.Environ(), Env...)
-	// Add environments of curent innstance.
-	cmd.Env = append(envTmp, b.Env...)
-	// Run command.
-
-	stopChan := make(chan struct{})
-	sigChan := StartSigTrap(cmd, stopChan)
-	defer sigChan.Close()
-	cmd.Start()
-	err := cmd.Wait()
-	stopChan <- struct{}{}
-	if ctx.Err() == context.DeadlineExceeded {
-		return fmt.Errorf("executor: command timeout '%s'", command)
-	}
+func (b *ShRunner) commandExecCommon(ctx context.Context, outputBuff io.Writer, errBuff io.Writer, command string, args ...string) error {
+	return b.execCmd(ctx, outputBuff, errBuff, command, args...)
+}
 
-	return err
+func (b *ShRunner) commandExecCommonInShell(ctx context.Context, command string, outputBuff io.Writer, errBuff io.Writer) error {
+	// Add set -e to handle errors in multiline commands.
+	return b.execCmd(ctx, outputBuff, errBuff, "sh", "-c", fmt.Sprintf("set -e\n%v", command))
 }
 
-func (b *ShRunner) commandExecCommonInShell(command string, outputBuff io.Writer, errBuff io.Writer) error {
-	// Prepere command, set outputs, run.
-	if config.Interrupted {
-		return fmt.Errorf("interrupted")
-	}
-	var ctx context.Context
-	var cancel context.CancelFunc
-	if b.Timeout == 0 {
-		ctx = context.Background()
-	} else {
-		ctx, cancel = context.WithTimeout(context.Background(), b.Timeout)
+// execCmd prepares command, sets outputs and runs it. The command runs in a separate process group,
+// so terminal signals are handled by cdev only. When the runner timeout is exceeded, the whole process group
+// gets SIGTERM to release the locks and save the state, and is killed after waitDelay. When the context is done
+// (the second interrupt), the process group is killed at once.
+func (b *ShRunner) execCmd(ctx context.Context, outputBuff io.Writer, errBuff io.Writer, command string, args ...string) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	}
+	if b.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
 		defer cancel()
 	}
-	// Add set -e to handle errors in multiline commands.
-	cmd := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf("set -e\n%v", command))
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Stdout = outputBuff
 	cmd.Stderr = errBuff
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var killTimer *time.Timer
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		if ctx.Err() != context.DeadlineExceeded {
+			return syscall.Kill(pgid, syscall.SIGKILL)
+		}
+		killTimer = time.AfterFunc(waitDelay, func() {
+			syscall.Kill(pgid, syscall.SIGKILL)
+		})
+		return syscall.Kill(pgid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = waitDelay
 
 	if b.workingDir != "" {
 		cmd.Dir = b.workingDir
@@ -123,16 +108,17 @@ func (b *ShRunner) commandExecCommonInShell(command string, outputBuff io.Writer
 	// Add environments of curent innstance.
 	cmd.Env = append(envTmp, b.Env...)
 	// Run command.
-	stopChan := make(chan struct{})
-	sigChan := StartSigTrap(cmd, stopChan)
-	defer sigChan.Close()
-	cmd.Start()
-	err := cmd.Wait()
-	stopChan <- struct{}{}
-	if ctx.Err() == context.DeadlineExceeded {
-		return fmt.Errorf("sh runner: command timeout '%s'", command)
+	err := cmd.Run()
+	if killTimer != nil && killTimer.Stop() {
+		// The shell is stopped, kill the rest of its process group, if any.
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if b.Timeout != 0 && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("sh runner: %w after %v", ErrTimeout, b.Timeout)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("killed: %w", ctx.Err())
 	}
-
 	return err
 }
 
@@ -158,11 +144,13 @@ func (b *ShRunner) RunWithTty(command string) error {
 
 // Run - exec command and return stdout, stderr, run error.
 func (b *ShRunner) Run(command string) ([]byte, []byte, error) {
+	return b.RunContext(context.Background(), command)
+}
 
-	var logPrefix string
-	for _, str := range b.LogLabels {
-		logPrefix = fmt.Sprintf("%s[%s]", logPrefix, str)
-	}
+// RunContext - exec command and return stdout, stderr, run error. The command is stopped when the context is done.
+func (b *ShRunner) RunContext(ctx context.Context, command string) ([]byte, []byte, error) {
+
+	logPrefix := LogPrefix(b.LogLabels)
 	log.Infof("%s %-7s", logPrefix, colors.Fmt(colors.LightWhiteBold).Sprint("In progress..."))
 	log.Debugf("%s Executing command '%s':", logPrefix, command)
 	// Create log writer.
@@ -187,7 +175,7 @@ func (b *ShRunner) Run(command string) ([]byte, []byte, error) {
 		}(bannerStopChan)
 	}
 	logCollector := newCollector(logWriter)
-	err = b.commandExecCommonInShell(command, logCollector, errOutput)
+	err = b.commandExecCommonInShell(ctx, command, logCollector, errOutput)
 	if b.ShowResultMessage {
 		if err == nil {
 			log.Infof("%s %-7s", logPrefix, colors.Fmt(colors.LightWhiteBold).Sprint("Success"))
@@ -209,10 +197,19 @@ func (b *ShRunner) RunMutely(command string, secrets ...string) (string, string,
 	// Mask secrets with ***
 	hiddenCommand := stringHideSecrets(command, secrets...)
 	log.Debugf("Executing command '%s':", hiddenCommand)
-	err := b.commandExecCommonInShell(command, output, runerr)
+	err := b.commandExecCommonInShell(context.Background(), command, output, runerr)
 	return output.String(), runerr.String(), err
 }
 
+// LogPrefix returns the log messages prefix for the runner log labels.
+func LogPrefix(labels []string) string {
+	var logPrefix string
+	for _, str := range labels {
+		logPrefix = fmt.Sprintf("%s[%s]", logPrefix, str)
+	}
+	return logPrefix
+}
+
 func stringHideSecrets(str string, secrets ...string) string {
 	hiddenStr := str
 	for _, s := range secrets {
@@ -235,36 +232,3 @@ func showBanner(banner string, done chan struct{}) {
 		}
 	}
 }
-
-type SigTrap chan os.Signal
-
-func StartSigTrap(cmd *exec.Cmd, stop chan struct{}) SigTrap {
-	sChan := make(chan os.Signal, 1)
-	signals := []os.Signal{syscall.SIGTERM, syscall.SIGINT}
-	signal.Notify(sChan, signals...)
-	go func() {
-		for {
-			select {
-			case s := <-sChan:
-				config.Interrupted = true
-				config.Global.LogLevel = "debug"
-				logging.InitLogLevel(config.Global.LogLevel, config.Global.TraceLog)
-				log.Debugf("executor: forward signal %v", s)
-				err := cmd.Process.Signal(s)
-				if err != nil {
-					log.Debugf("Error forwarding signal: %v", err)
-				}
-			case <-stop:
-				return
-			}
-		}
-	}()
-	return sChan
-}
-
-func (signalChannel *SigTrap) Close() error {
-	signal.Stop(*signalChannel)
-	*signalChannel <- nil
-	close(*signalChannel)
-	return nil
-}
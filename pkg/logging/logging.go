@@ -2,10 +2,12 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/colors"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 )
 
 // color function.
@@ -32,9 +34,17 @@ var Strings = [...]string{
 // utilStartTime time.
 var utilStartTime = time.Now()
 
+// stdHandler - default log handler.
+var stdHandler = NewLogStdHandler()
+
 // loggingInit - initial function for logging subsystem.
 func init() {
-	log.SetHandler(NewLogStdHandler())
+	log.SetHandler(stdHandler)
+}
+
+// SetOutput redirects log messages to w. Used to keep stdout clean for machine-readable output.
+func SetOutput(w io.Writer) {
+	stdHandler.SetOutput(w)
 }
 
 var traceLog bool
@@ -77,5 +87,5 @@ func logFormatter(e *log.Entry) string {
 	}
 	output = fmt.Sprintf("%s %-25s", output, e.Message)
 
-	return fmt.Sprintln(output)
+	return fmt.Sprintln(sensitive.MaskString(output))
 }
@@ -11,7 +11,9 @@ import (
 
 // NewLogStdHandler - new custom log handler for apex log lib.
 func NewLogStdHandler() *StdHandler {
-	return &StdHandler{}
+	return &StdHandler{
+		Writer: os.Stdout,
+	}
 }
 
 // StdHandler implementation.
@@ -32,7 +34,14 @@ func (h *StdHandler) HandleLog(e *log.Entry) error {
 	// 	h.Writer = os.Stdout
 	// }
 
-	fmt.Fprint(os.Stdout, logFormatter(e))
+	fmt.Fprint(h.Writer, logFormatter(e))
 
 	return nil
 }
+
+// SetOutput sets the writer for log messages (stdout by default).
+func (h *StdHandler) SetOutput(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Writer = w
+}
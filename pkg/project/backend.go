@@ -1,6 +1,7 @@
 package project
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/apex/log"
@@ -18,24 +19,57 @@ spec:
     path: ""
 `
 
-// Backend interface for backend provider.
+// ErrStateLocked is returned by Backend.LockState when the state is already locked by another process.
+var ErrStateLocked = errors.New("the state is locked")
+
+// ErrStateVersionNotFound is returned by StateHistoryBackend.ReadStateVersion when the version does not exist.
+var ErrStateVersionNotFound = errors.New("state version not found")
+
+// Backend interface for backend provider. LockState must be atomic: when several processes try to lock
+// the state at the same time, only one of them succeeds, all others get ErrStateLocked. The lock info
+// is saved with the lock and returned by ReadLock.
+// UnlockState releases the lock, even if it was acquired by another process (force unlock).
 type Backend interface {
 	Name() string
 	Provider() string
 	GetBackendHCL(string, string) (*hclwrite.File, error)
 	GetBackendBytes(string, string) ([]byte, error)
 	GetRemoteStateHCL(string, string) ([]byte, error)
-	LockState() error
+	LockState(lockInfo string) error
+	ReadLock() (lockInfo string, locked bool, err error)
 	UnlockState() error
 	WriteState(stateData string) error
 	ReadState() (string, error)
 }
 
+// StateHistoryBackend is implemented by backends which can keep the state history. Versions are numbered
+// from 1, the history index describes them. ReadStateHistory returns an empty string if there is no history yet.
+type StateHistoryBackend interface {
+	ReadStateHistory() (string, error)
+	WriteStateHistory(history string) error
+	ReadStateVersion(version int) (string, error)
+	WriteStateVersion(version int, stateData string) error
+	DeleteStateVersion(version int) error
+}
+
+// LockProbeBackend is implemented by backends, which can read the lock only by trying to take it: ReadLock
+// takes the lock for a moment if the state is not locked. The lock is not read from such backends by the
+// commands, which only show it.
+type LockProbeBackend interface {
+	ProbesLock() bool
+}
+
 // BackendsFactory - interface for backend provider factory. New() creates backend.
 type BackendsFactory interface {
 	New([]byte, string, *Project) (Backend, error)
 }
 
+// BackendSpecChecker is implemented by the backend factories which can check the backend spec without
+// creating the backend, so the backend storage is not accessed. Used by the project validation.
+type BackendSpecChecker interface {
+	CheckSpec(spec []byte) error
+}
+
 // RegisterBackendFactory - register factory of some provider (like s3) in map.
 func RegisterBackendFactory(f BackendsFactory, provider string) error {
 	if _, exists := BackendsFactories[provider]; exists {
@@ -83,6 +117,15 @@ func (p *Project) readBackendObj(obj ObjectData) error {
 	if !exists {
 		return fmt.Errorf("'%v': provider does not found: %v", name, provider)
 	}
+	if p.offline {
+		if checker, ok := factory.(BackendSpecChecker); ok {
+			if err := checker.CheckSpec(rawSpec); err != nil {
+				return fmt.Errorf("'%v': %w", name, err)
+			}
+		}
+		p.Backends[name] = &offlineBackend{name: name, provider: provider}
+		return nil
+	}
 	b, err := factory.New(rawSpec, name, p)
 	if err != nil {
 		return err
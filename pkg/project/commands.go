@@ -1,10 +1,8 @@
 package project
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
 
 	"github.com/apex/log"
 	"github.com/paulrademacher/climenu"
@@ -29,9 +27,13 @@ func (p *Project) Build() error {
 }
 
 // Destroy all units.
-func (p *Project) Destroy() error {
-	planStatus := ProjectPlanningStatus{}
-	p.planDestroyAll(&planStatus)
+func (p *Project) Destroy(ctx context.Context) error {
+	planStatus := &ProjectPlanningStatus{}
+	p.planDestroyAll(planStatus)
+	planStatus, err := planStatus.TargetsFilter(config.NewTargetsChecker(config.Global.Targets), true)
+	if err != nil {
+		return fmt.Errorf("build destroy graph: %w", err)
+	}
 	destroyGraph, err := planStatus.BuildGraph()
 	if err != nil {
 		return fmt.Errorf("build destroy graph: %w", err)
@@ -41,15 +43,15 @@ func (p *Project) Destroy() error {
 		return nil
 	}
 	if !config.Global.Force {
-		stopChan := make(chan struct{})
-		p.StartSigTrap(stopChan)
 		showPlanResults(destroyGraph)
 		if p.NewVersionMessage != "" {
 			log.Info(p.NewVersionMessage)
 		}
-		respond := climenu.GetText("Continue?(yes/no)", "no")
-		stopChan <- struct{}{}
-		if respond != "yes" {
+		confirmed, err := askConfirmation(ctx)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
 			log.Info("Destroying cancelled")
 			return nil
 		}
@@ -59,31 +61,40 @@ func (p *Project) Destroy() error {
 		return fmt.Errorf("project destroy: clear cache dir: %w", err)
 	}
 	log.Info("Destroying...")
+	p.OwnState.StartAutoSave()
+	defer p.OwnState.StopAutoSave()
 	for {
 		// log.Warnf("FOR Project apply. Unit links: %+v", p.UnitLinks)
 		if destroyGraph.Len() == 0 {
-			return p.OwnState.SaveState()
+			return p.finishExecution(destroyGraph)
 		}
-		gUnit, fn, err := destroyGraph.GetNextAsync()
+		gUnit, fn, err := destroyGraph.GetNextAsync(ctx)
 		if err != nil {
 			unitName := ""
 			if gUnit != nil {
 				unitName = gUnit.UnitPtr.Key()
 			}
-			log.Errorf("error in unit %v, waiting for all running units done.", unitName)
+			if ctx.Err() != nil {
+				log.Warnf("Execution interrupted, waiting for all running units done.")
+			} else {
+				log.Errorf("error in unit %v, waiting for all running units done.", unitName)
+			}
 			destroyGraph.Wait()
 			for _, e := range destroyGraph.Errors() {
-				log.Errorf("unit: '%v':\n%v", e.Key(), e.ExecError())
+				log.Errorf("unit: '%v':\n%v", e.UnitPtr.Key(), e.ExecError())
 			}
 			err := p.OwnState.SaveState()
 			if err != nil {
 				return fmt.Errorf("save state after error: %w", err)
 			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("interrupted, results of finished units are saved to the state: %w", ctx.Err())
+			}
 			return fmt.Errorf("applying error")
 		}
 		// Check if graph return nil unit - applying finished, return
 		if gUnit == nil {
-			return p.OwnState.SaveState()
+			return p.finishExecution(destroyGraph)
 		}
 		switch gUnit.Operation {
 		case Apply, Update:
@@ -91,38 +102,13 @@ func (p *Project) Destroy() error {
 			return fmt.Errorf("destroy: internal error, found unit for apply in destroy command")
 		case Destroy:
 			// log.Warnf("DESTROY circle: run DESTROY for unit: %v", gUnit.UnitPtr.Key())
-			go destroyRoutine(gUnit, fn, p)
+			go destroyRoutine(unitsContext(ctx), gUnit, fn, p)
 		}
 	}
 }
 
-func (p *Project) StartSigTrap(stop chan struct{}) {
-	p.HupUnlockChan = make(chan os.Signal, 1)
-	signals := []os.Signal{syscall.SIGTERM, syscall.SIGINT}
-	signal.Notify(p.HupUnlockChan, signals...)
-	go func() {
-		for {
-			select {
-			case <-p.HupUnlockChan:
-				fmt.Println()
-				log.Warnf("Execution halted. Unlocking state and exiting...")
-				p.UnLockState()
-				close(p.HupUnlockChan)
-				os.Exit(0)
-			case <-stop:
-				close(p.HupUnlockChan)
-				return
-			}
-		}
-	}()
-}
-
-func (p *Project) StopSigTrap() {
-	close(p.HupUnlockChan)
-}
-
 // Apply all units.
-func (p *Project) Apply() error {
+func (p *Project) Apply(ctx context.Context) error {
 	// var applyGraph *ProjectPlanningStatus
 	applyGraph, err := p.Plan()
 	if err != nil {
@@ -135,61 +121,110 @@ func (p *Project) Apply() error {
 		if p.NewVersionMessage != "" {
 			log.Info(p.NewVersionMessage)
 		}
-		stopChan := make(chan struct{})
-		p.StartSigTrap(stopChan)
-		respond := climenu.GetText("Continue?(yes/no)", "no")
-		stopChan <- struct{}{}
-		if respond != "yes" {
+		confirmed, err := askConfirmation(ctx)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
 			log.Info("Cancelled")
 			return nil
 		}
 	}
-	err = p.ClearCacheDir()
+	return p.runApply(ctx, applyGraph)
+}
+
+// askConfirmation asks user to continue the operation. Returns error if the context is done while waiting for the answer.
+func askConfirmation(ctx context.Context) (bool, error) {
+	respond := make(chan string, 1)
+	go func() {
+		respond <- climenu.GetText("Continue?(yes/no)", "no")
+	}()
+	select {
+	case r := <-respond:
+		return r == "yes", nil
+	case <-ctx.Done():
+		fmt.Println()
+		return false, fmt.Errorf("interrupted: %w", ctx.Err())
+	}
+}
+
+// runApply applies the units of the graph in dependency order.
+func (p *Project) runApply(ctx context.Context, applyGraph *graph) error {
+	err := p.ClearCacheDir()
 	if err != nil {
 		return fmt.Errorf("project apply: clear cache dir: %v", err.Error())
 	}
 	log.Info("Applying...")
+	p.OwnState.StartAutoSave()
+	defer p.OwnState.StopAutoSave()
 
 	for {
 		// log.Warnf("FOR Project apply. Unit links: %+v", p.UnitLinks)
 		if applyGraph.Len() == 0 {
-			return p.OwnState.SaveState()
+			return p.finishExecution(applyGraph)
 		}
-		gUnit, fn, err := applyGraph.GetNextAsync()
+		gUnit, fn, err := applyGraph.GetNextAsync(ctx)
 		if err != nil {
 			unitName := ""
 			if gUnit != nil {
 				unitName = gUnit.UnitPtr.Key()
 			}
-			log.Errorf("error in unit %v, waiting for all running units done.", unitName)
+			if ctx.Err() != nil {
+				log.Warnf("Execution interrupted, waiting for all running units done.")
+			} else {
+				log.Errorf("error in unit %v, waiting for all running units done.", unitName)
+			}
 			applyGraph.Wait()
 			for _, e := range applyGraph.Errors() {
-				log.Errorf("unit: '%v':\n%v", e.Key(), e.ExecError())
+				log.Errorf("unit: '%v':\n%v", e.UnitPtr.Key(), e.ExecError())
 			}
 			err := p.OwnState.SaveState()
 			if err != nil {
 				return fmt.Errorf("save state after error: %w", err)
 			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("interrupted, results of finished units are saved to the state: %w", ctx.Err())
+			}
 			return fmt.Errorf("applying error")
 		}
 		// Check if graph return nil unit - applying finished, return
 		if gUnit == nil {
-			p.OwnState.SaveState()
-			return nil
+			return p.finishExecution(applyGraph)
 		}
 		switch gUnit.Operation {
 		case Apply, Update:
 			// log.Warnf("APPLY circle: run APPLY for unit: %v", gUnit.UnitPtr.Key())
-			go applyRoutine(gUnit, fn, p)
+			go applyRoutine(unitsContext(ctx), gUnit, fn, p)
 		case Destroy:
 			// log.Warnf("APPLY circle: run DESTROY for unit: %v", gUnit.UnitPtr.Key())
-			go destroyRoutine(gUnit, fn, p)
+			go destroyRoutine(unitsContext(ctx), gUnit, fn, p)
 		}
 	}
 }
 
+// finishExecution saves the state after all graph units were processed. In keep-going mode prints
+// the execution summary and returns error if some units failed.
+func (p *Project) finishExecution(g *graph) error {
+	err := p.OwnState.SaveState()
+	if err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	if !g.keepGoing {
+		return nil
+	}
+	g.PrintSummary()
+	if g.HasFailed() {
+		for _, e := range g.Errors() {
+			log.Errorf("unit: '%v':\n%v", e.UnitPtr.Key(), e.ExecError())
+		}
+		_, failed, skipped := g.Summary()
+		return fmt.Errorf("%v unit(s) failed, %v unit(s) skipped", len(failed), len(skipped))
+	}
+	return nil
+}
+
 // applyRoutine function to run unit apply in parallel
-func applyRoutine(graphUnit *UnitPlanningStatus, finFunc func(error), p *Project) {
+func applyRoutine(ctx context.Context, graphUnit *UnitPlanningStatus, finFunc func(error), p *Project) {
 	log.Infof(colors.Fmt(colors.LightWhiteBold).Sprintf("Applying unit '%v':", graphUnit.UnitPtr.Key()))
 	err := graphUnit.UnitPtr.Build()
 	if err != nil {
@@ -198,13 +233,14 @@ func applyRoutine(graphUnit *UnitPlanningStatus, finFunc func(error), p *Project
 		return
 	}
 	p.ProcessedUnitsCount++
-	err = graphUnit.UnitPtr.Apply()
+	err = graphUnit.UnitPtr.Apply(ctx)
 	if err != nil {
 		state, _ := utils.JSONEncode(graphUnit.UnitPtr)
 		log.Warnf("applyRoutine: %v", string(state))
 		if graphUnit.UnitPtr.IsTainted() {
 			// log.Warnf("applyRoutine: tainted %v", graphUnit.UnitPtr.Key())
 			p.OwnState.UpdateUnit(graphUnit.UnitPtr)
+			p.OwnState.RequestSave()
 		}
 		finFunc(fmt.Errorf("apply unit: %v", err.Error()))
 		return
@@ -215,12 +251,13 @@ func applyRoutine(graphUnit *UnitPlanningStatus, finFunc func(error), p *Project
 		return
 	}
 	p.OwnState.UpdateUnit(graphUnit.UnitPtr)
+	p.OwnState.RequestSave()
 	graphUnit.UnitPtr.SetExecStatus(Finished)
 	finFunc(nil)
 }
 
 // destroyRoutine function to run unit destroy in parallel
-func destroyRoutine(graphUnit *UnitPlanningStatus, finFunc func(error), p *Project) {
+func destroyRoutine(ctx context.Context, graphUnit *UnitPlanningStatus, finFunc func(error), p *Project) {
 	log.Infof(colors.Fmt(colors.LightWhiteBold).Sprintf("Destroying unit '%v':", graphUnit.UnitPtr.Key()))
 	err := graphUnit.UnitPtr.Build()
 	if err != nil {
@@ -229,12 +266,15 @@ func destroyRoutine(graphUnit *UnitPlanningStatus, finFunc func(error), p *Proje
 		return
 	}
 	p.ProcessedUnitsCount++
-	err = graphUnit.UnitPtr.Destroy()
+	err = graphUnit.UnitPtr.Destroy(ctx)
 	if err != nil {
+		// Save the tainted flag.
+		p.OwnState.RequestSave()
 		finFunc(fmt.Errorf("destroy unit: %v", err.Error()))
 		return
 	}
 	p.OwnState.DeleteUnit(graphUnit.UnitPtr)
+	p.OwnState.RequestSave()
 	graphUnit.UnitPtr.SetExecStatus(Finished)
 	finFunc(nil)
 }
@@ -246,7 +286,9 @@ func (p *Project) Plan() (*graph, error) {
 	if err != nil {
 		return nil, err
 	}
-	showPlanResults(planningSt)
+	if !config.Global.OutputJSON {
+		showPlanResults(planningSt)
+	}
 	return planningSt, nil
 }
 
@@ -299,6 +341,10 @@ func (p *Project) buildPlan() (resGraph *graph, err error) {
 		for _, u := range p.UnitsSlice() {
 			planningStatus.Add(u, Apply, utils.Diff(nil, u.GetDiffData(), true), false)
 		}
+		planningStatus, err = planningStatus.TargetsFilter(config.NewTargetsChecker(config.Global.Targets), false)
+		if err != nil {
+			return nil, err
+		}
 		return planningStatus.BuildGraph()
 	}
 	p.planDestroy(planningStatus)
@@ -349,6 +395,10 @@ func (p *Project) buildPlan() (resGraph *graph, err error) {
 	// 		break
 	// 	}
 	// }
+	planningStatus, err = planningStatus.TargetsFilter(config.NewTargetsChecker(config.Global.Targets), false)
+	if err != nil {
+		return nil, err
+	}
 	// Check graph and set sequence indexes
 	resGraph, err = planningStatus.BuildGraph()
 	if err != nil {
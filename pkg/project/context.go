@@ -0,0 +1,31 @@
+package project
+
+import "context"
+
+type unitsContextKey struct{}
+
+type interruptContextKey struct{}
+
+// WithUnitsContext returns a copy of ctx, which carries the separate context for running units.
+// Cancellation of ctx stops scheduling of new units and waits for the running ones,
+// which are killed only when unitsCtx is done. Without it, the units use ctx itself.
+func WithUnitsContext(ctx, unitsCtx context.Context) context.Context {
+	return context.WithValue(ctx, unitsContextKey{}, unitsCtx)
+}
+
+// unitsContext returns the context for running units. It carries ctx, see InterruptContext.
+func unitsContext(ctx context.Context) context.Context {
+	if unitsCtx, ok := ctx.Value(unitsContextKey{}).(context.Context); ok {
+		return context.WithValue(unitsCtx, interruptContextKey{}, ctx)
+	}
+	return ctx
+}
+
+// InterruptContext returns the context, which is cancelled on the first interrupt, from the context of
+// the running unit. The unit should not start the new work, like the next retry attempt, when it is done.
+func InterruptContext(unitCtx context.Context) context.Context {
+	if ctx, ok := unitCtx.Value(interruptContextKey{}).(context.Context); ok {
+		return ctx
+	}
+	return unitCtx
+}
@@ -0,0 +1,43 @@
+package project
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnitsContextDefault(t *testing.T) {
+	// Without the separate units context the units use the command context: the interrupt cancels them.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	unitCtx := unitsContext(ctx)
+	if unitCtx != ctx {
+		t.Error("expected the command context")
+	}
+	if InterruptContext(unitCtx) != ctx {
+		t.Error("expected the same interrupt context")
+	}
+	cancel()
+	if unitCtx.Err() == nil {
+		t.Error("the unit context should be cancelled by the interrupt")
+	}
+}
+
+func TestInterruptContext(t *testing.T) {
+	ctx, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	unitsCtx, kill := context.WithCancel(context.Background())
+	defer kill()
+
+	unitCtx := unitsContext(WithUnitsContext(ctx, unitsCtx))
+	interrupt()
+	if unitCtx.Err() != nil {
+		t.Fatal("the unit context should not be cancelled by the first interrupt")
+	}
+	if InterruptContext(unitCtx).Err() == nil {
+		t.Fatal("the interrupt context should be cancelled")
+	}
+	kill()
+	if unitCtx.Err() == nil {
+		t.Fatal("the unit context should be cancelled by the second interrupt")
+	}
+}
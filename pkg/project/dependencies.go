@@ -20,6 +20,10 @@ func checkDependenciesRecursive(unit Unit, chain ...string) error {
 	}
 	chain = append(chain, unit.Key())
 	for _, dep := range unit.Dependencies().Slice() {
+		// Unresolved links are reported by the validation before the loops check.
+		if dep.Unit == nil {
+			continue
+		}
 		if err := checkDependenciesRecursive(dep.Unit, chain...); err != nil {
 			return err
 		}
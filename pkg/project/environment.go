@@ -0,0 +1,117 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// envsDirName the directory with the environments overlays, each environment is a subdirectory.
+const envsDirName = "envs"
+
+var envNameRegexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_-]*$")
+
+// Env returns the active environment name, empty if the project is used without environment.
+func (p *Project) Env() string {
+	return p.env
+}
+
+// readEnvManifests reads the overlay files of the active environment. The project config overlay is
+// kept separately, other files are added to the project objects files and merged after the base objects.
+func (p *Project) readEnvManifests(objFiles map[string][]byte) error {
+	p.env = config.Global.Env
+	if p.env == "" {
+		return nil
+	}
+	if !envNameRegexp.MatchString(p.env) {
+		return fmt.Errorf("environment '%v': invalid name, only letters, digits, '-' and '_' are allowed", p.env)
+	}
+	envDir := filepath.Join(config.Global.WorkingDir, envsDirName, p.env)
+	isDir, err := utils.CheckDir(envDir)
+	if err != nil || !isDir {
+		return fmt.Errorf("environment '%v': directory '%v' not found", p.env, filepath.Join(envsDirName, p.env))
+	}
+	files, _ := filepath.Glob(envDir + "/*.yaml")
+	filesYML, _ := filepath.Glob(envDir + "/*.yml")
+	files = append(files, filesYML...)
+	p.envFiles = map[string]bool{}
+	for _, file := range files {
+		if regexp.MustCompile(ConfigFilePattern).MatchString(filepath.Base(file)) {
+			p.envConfigDataFile, err = os.ReadFile(file)
+		} else {
+			objFiles[file], err = os.ReadFile(file)
+			p.envFiles[file] = true
+		}
+		if err != nil {
+			return fmt.Errorf("reading environment configs %v: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// isEnvFile returns true if the file is the environment overlay.
+func (p *Project) isEnvFile(fileName string) bool {
+	return p.envFiles[fileName]
+}
+
+// mergeEnvProjectConfig merges the environment overlay of the project config into the parsed project config.
+func (p *Project) mergeEnvProjectConfig(prjConf map[string]interface{}) error {
+	if p.envConfigDataFile == nil {
+		return nil
+	}
+	overlay := map[string]interface{}{}
+	err := yaml.Unmarshal(p.envConfigDataFile, &overlay)
+	if err != nil {
+		return fmt.Errorf("parsing environment '%v' project config: %v", p.env, utils.ResolveYamlError(p.envConfigDataFile, err))
+	}
+	if kind, exists := overlay["kind"]; exists && kind != projectObjKindKey {
+		return fmt.Errorf("parsing environment '%v' project config: unexpected kind '%v'", p.env, kind)
+	}
+	if name, exists := overlay["name"]; exists && name != prjConf["name"] {
+		return fmt.Errorf("parsing environment '%v' project config: project name can't be changed by environment", p.env)
+	}
+	utils.DeepMergeMaps(prjConf, overlay)
+	return nil
+}
+
+// mergeEnvObject merges the environment overlay object into the base object with the same kind and name.
+// Objects which do not exist in the base manifests are added.
+func (p *Project) mergeEnvObject(objKind string, obj map[string]interface{}, filename string) error {
+	name, ok := obj["name"].(string)
+	if !ok {
+		rel, _ := filepath.Rel(config.Global.WorkingDir, filename)
+		return fmt.Errorf("environment '%v': object in '%v' must contain field 'name'", p.env, rel)
+	}
+	for _, base := range p.objects[objKind] {
+		if base.data["name"] == name && !p.isEnvFile(base.filename) {
+			utils.DeepMergeMaps(base.data, obj)
+			return nil
+		}
+	}
+	p.objects[objKind] = append(p.objects[objKind], ObjectData{
+		filename: filename,
+		data:     obj,
+	})
+	return nil
+}
+
+// sortedObjectsFiles returns the names of the objects files: base manifests first, then environment overlays.
+func (p *Project) sortedObjectsFiles() []string {
+	res := make([]string, 0, len(p.objectsFiles))
+	for filename := range p.objectsFiles {
+		res = append(res, filename)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if p.isEnvFile(res[i]) != p.isEnvFile(res[j]) {
+			return !p.isEnvFile(res[i])
+		}
+		return res[i] < res[j]
+	})
+	return res
+}
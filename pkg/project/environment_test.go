@@ -0,0 +1,60 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvOverlay(t *testing.T) {
+	p := &Project{
+		env:      "prod",
+		objects:  map[string][]ObjectData{},
+		envFiles: map[string]bool{"envs/prod/stacks.yaml": true},
+	}
+	base := []byte("name: infra\nkind: Stack\nbackend: default\nvariables:\n  region: eu-west-1\n  size: small\n")
+	overlay := []byte("name: infra\nkind: Stack\nbackend: s3\nvariables:\n  size: large\n---\nname: extra\nkind: Stack\n")
+	if err := p.readObjects(base, "stack.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.readObjects(overlay, "envs/prod/stacks.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	stacks := p.objects[stackObjKindKey]
+	if len(stacks) != 2 {
+		t.Fatalf("expected 2 stacks, got %v", len(stacks))
+	}
+	expected := map[string]interface{}{
+		"name":      "infra",
+		"kind":      "Stack",
+		"backend":   "s3",
+		"variables": map[string]interface{}{"region": "eu-west-1", "size": "large"},
+	}
+	if !reflect.DeepEqual(stacks[0].data, expected) {
+		t.Errorf("unexpected merged stack: %v", stacks[0].data)
+	}
+	if stacks[1].data["name"] != "extra" {
+		t.Errorf("environment stack was not added: %v", stacks[1].data)
+	}
+	if err := p.readObjects([]byte("kind: Stack\n"), "envs/prod/stacks.yaml"); err == nil {
+		t.Error("expected error for overlay object without name")
+	}
+}
+
+func TestEnvProjectConfig(t *testing.T) {
+	p := &Project{env: "prod", envConfigDataFile: []byte("kind: Project\nbackend: s3\nvariables:\n  region: us-east-1\n")}
+	prjConf := map[string]interface{}{
+		"name":      "test",
+		"backend":   "default",
+		"variables": map[string]interface{}{"region": "eu-west-1", "org": "shalb"},
+	}
+	if err := p.mergeEnvProjectConfig(prjConf); err != nil {
+		t.Fatal(err)
+	}
+	if prjConf["backend"] != "s3" || !reflect.DeepEqual(prjConf["variables"], map[string]interface{}{"region": "us-east-1", "org": "shalb"}) {
+		t.Errorf("unexpected project config: %v", prjConf)
+	}
+	p.envConfigDataFile = []byte("name: other\n")
+	if err := p.mergeEnvProjectConfig(prjConf); err == nil {
+		t.Error("expected error for changed project name")
+	}
+}
@@ -0,0 +1,14 @@
+package project
+
+// The backends import the project package, so the tests against the real backends are in the project_test
+// package. The functions below give them access to the project internals.
+
+const DefaultStateHistoryLimit = defaultStateHistoryLimit
+
+func (p *Project) SaveStateVersion(stateData string) error {
+	return p.saveStateVersion(stateData)
+}
+
+func (p *Project) SetStateHistoryLimit(limit int) {
+	p.stateHistoryLimit = limit
+}
@@ -1,10 +1,15 @@
 package project
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sort"
 	"sync"
 
 	"github.com/apex/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shalb/cluster.dev/pkg/colors"
 	"github.com/shalb/cluster.dev/pkg/config"
 )
 
@@ -15,6 +20,7 @@ const (
 	ReadyForExec
 	InProgress
 	Finished
+	Skipped
 )
 
 type ExecSet struct {
@@ -110,6 +116,7 @@ type graph struct {
 	maxParallel   int
 	indexedSlice  []*UnitPlanningStatus
 	planningUnits *ProjectPlanningStatus
+	keepGoing     bool
 	// sigTrap      chan os.Signal
 	// stopChan     chan struct{}
 }
@@ -119,22 +126,24 @@ func (g *graph) BuildNew(planningStatus *ProjectPlanningStatus, maxParallel int)
 	g.maxParallel = maxParallel
 	g.waitUnitDone = make(chan *UnitPlanningStatus)
 	g.planningUnits = planningStatus
+	g.keepGoing = config.Global.KeepGoing
 	return g.checkAndBuildIndexes()
 	// g.listenHupSig()
 }
 
-func (g *graph) GetNextAsync() (*UnitPlanningStatus, func(error), error) {
+func (g *graph) GetNextAsync(ctx context.Context) (*UnitPlanningStatus, func(error), error) {
 	g.mux.Lock()
 	defer g.mux.Unlock()
 	for {
 		g.updateQueueNew()
-		if config.Interrupted {
-			return nil, nil, fmt.Errorf("interrupted")
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("interrupted: %w", ctx.Err())
 		}
 		readyFroExecList := g.units.StatusFilter(ReadyForExec)
 		if readyFroExecList.Len() > 0 && g.units.StatusFilter(InProgress).Len() < g.maxParallel {
 			unitForExec := readyFroExecList.Front()
 			finFunc := func(err error) {
+				unitForExec.ExecErr = err
 				g.waitUnitDone <- unitForExec
 			}
 			unitForExec.UnitPtr.SetExecStatus(InProgress)
@@ -146,13 +155,122 @@ func (g *graph) GetNextAsync() (*UnitPlanningStatus, func(error), error) {
 		}
 		unitFinished := <-g.waitUnitDone
 		unitFinished.UnitPtr.SetExecStatus(Finished)
+		if unitFinished.Failed() && g.keepGoing {
+			// Skip units which can't be processed without the failed one, continue with others.
+			g.skipBlockedUnits()
+		}
 		g.updateQueueNew()
-		if unitFinished.UnitPtr.ExecError() != nil {
+		if unitFinished.Failed() && !g.keepGoing {
 			return unitFinished, nil, fmt.Errorf("error while unit running")
 		}
 	}
 }
 
+// skipBlockedUnits marks as skipped all units, which are waiting for failed or skipped units.
+// For apply/update it is all transitive dependents, for destroy - all transitive dependencies.
+func (g *graph) skipBlockedUnits() {
+	for {
+		blocked := map[string]*UnitPlanningStatus{}
+		for _, u := range g.units.Slice() {
+			if u.Failed() || u.UnitPtr.GetExecStatus() == Skipped {
+				blocked[u.UnitPtr.Key()] = u
+			}
+		}
+		skippedCount := 0
+		for _, u := range g.units.StatusFilter(Backlog, ReadyForExec).Slice() {
+			if g.isBlocked(u, blocked) {
+				log.Warnf("Unit '%v' will be skipped because of failed dependencies", u.UnitPtr.Key())
+				u.UnitPtr.SetExecStatus(Skipped)
+				skippedCount++
+			}
+		}
+		if skippedCount == 0 {
+			return
+		}
+	}
+}
+
+func (g *graph) isBlocked(u *UnitPlanningStatus, blocked map[string]*UnitPlanningStatus) bool {
+	switch u.Operation {
+	case Apply, Update:
+		for _, dep := range u.UnitPtr.Dependencies().Slice() {
+			if _, exists := blocked[dep.UnitKey()]; exists {
+				return true
+			}
+		}
+	case Destroy:
+		for _, bu := range blocked {
+			if bu.Operation != Destroy {
+				continue
+			}
+			for _, dep := range bu.UnitPtr.Dependencies().Slice() {
+				if dep.UnitKey() == u.UnitPtr.Key() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Summary returns keys of succeeded, failed and skipped units.
+func (g *graph) Summary() (succeeded, failed, skipped []string) {
+	for _, u := range g.units.Slice() {
+		if u.Operation == NotChanged {
+			continue
+		}
+		switch {
+		case u.Failed():
+			failed = append(failed, u.UnitPtr.Key())
+		case u.UnitPtr.GetExecStatus() == Skipped:
+			skipped = append(skipped, u.UnitPtr.Key())
+		case u.UnitPtr.GetExecStatus() == Finished:
+			succeeded = append(succeeded, u.UnitPtr.Key())
+		}
+	}
+	sort.Strings(succeeded)
+	sort.Strings(failed)
+	sort.Strings(skipped)
+	return
+}
+
+// PrintSummary prints execution results of the graph units.
+func (g *graph) PrintSummary() {
+	succeeded, failed, skipped := g.Summary()
+	log.Infof(colors.Fmt(colors.LightWhiteBold).Sprint("Execution summary:"))
+	headers := []string{}
+	row := []string{}
+	for _, col := range []struct {
+		header string
+		color  colors.Color
+		keys   []string
+	}{
+		{"Succeeded", colors.Green, succeeded},
+		{"Failed", colors.Red, failed},
+		{"Skipped", colors.Yellow, skipped},
+	} {
+		if len(col.keys) == 0 {
+			continue
+		}
+		cell := ""
+		for _, key := range col.keys {
+			if len(cell) != 0 {
+				cell += "\n"
+			}
+			cell += colors.Fmt(col.color).Sprint(key)
+		}
+		headers = append(headers, col.header)
+		row = append(row, cell)
+	}
+	if len(headers) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(headers)
+	table.Append(row)
+	table.Render()
+}
+
 func (g *graph) checkAndBuildIndexes() error {
 	i := 0
 	g.indexedSlice = []*UnitPlanningStatus{}
@@ -205,16 +323,32 @@ func (g *graph) resetUnitsStatus() {
 	}
 }
 
-func (g *graph) Errors() []Unit {
-	res := []Unit{}
+// Errors returns the units, which finished with error.
+func (g *graph) Errors() []*UnitPlanningStatus {
+	res := []*UnitPlanningStatus{}
 	for _, u := range g.units.Slice() {
-		if u.UnitPtr.ExecError() != nil {
-			res = append(res, u.UnitPtr)
+		if u.Failed() {
+			res = append(res, u)
 		}
 	}
 	return res
 }
 
+// HasFailed returns true if any unit of the graph finished with error.
+func (g *graph) HasFailed() bool {
+	for _, u := range g.units.Slice() {
+		if u.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasChanges returns true if the graph contains units for apply, update or destroy.
+func (g *graph) HasChanges() bool {
+	return g.planningUnits.HasChanges()
+}
+
 func (g *graph) Len() int {
 	return g.units.StatusFilter(Backlog, InProgress, ReadyForExec).Len()
 }
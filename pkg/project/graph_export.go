@@ -0,0 +1,259 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// GraphNode describes unit in the exported dependency graph.
+type GraphNode struct {
+	Key       string `json:"key"`
+	Stack     string `json:"stack"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Operation string `json:"operation,omitempty"`
+}
+
+// GraphEdge describes dependency between two units. From is the dependency, To is the unit which uses it.
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	LinkType string `json:"link_type"`
+	Output   string `json:"output,omitempty"`
+}
+
+// GraphExport describes the project units dependency graph.
+type GraphExport struct {
+	Project string      `json:"project"`
+	Nodes   []GraphNode `json:"nodes"`
+	Edges   []GraphEdge `json:"edges"`
+}
+
+// RemoteStateLinkType - link type of the terraform units remote state markers.
+const RemoteStateLinkType = "RemoteStateMarkers"
+
+// CustomLinkType - link type of the unit 'depends_on' dependencies.
+const CustomLinkType = "custom"
+
+// linkTypeNames maps internal unit link types to the names used in project configuration.
+var linkTypeNames = map[string]string{
+	OutputLinkType:      "output",
+	RemoteStateLinkType: "remoteState",
+	CustomLinkType:      "depends_on",
+}
+
+// GraphExportFormats list of supported graph export formats.
+var GraphExportFormats = []string{"dot", "mermaid", "json"}
+
+func linkTypeName(linkType string) string {
+	if name, exists := linkTypeNames[linkType]; exists {
+		return name
+	}
+	return linkType
+}
+
+// NewGraphExport collects units and dependencies of the project. If withPlan is set, the planned operation of each unit is added.
+func (p *Project) NewGraphExport(withPlan bool) (*GraphExport, error) {
+	res := GraphExport{
+		Project: p.Name(),
+		Nodes:   []GraphNode{},
+		Edges:   []GraphEdge{},
+	}
+	units := map[string]Unit{}
+	for key, unit := range p.Units {
+		units[key] = unit
+	}
+	operations := map[string]string{}
+	if withPlan {
+		planGraph, err := p.buildPlan()
+		if err != nil {
+			return nil, fmt.Errorf("export graph: %w", err)
+		}
+		for _, us := range planGraph.planningUnits.Slice() {
+			operations[us.UnitPtr.Key()] = us.Operation.Name()
+			if _, exists := units[us.UnitPtr.Key()]; !exists {
+				// Unit exists only in state and will be destroyed.
+				units[us.UnitPtr.Key()] = us.UnitPtr
+			}
+		}
+	}
+	edges := map[GraphEdge]bool{}
+	for key, unit := range units {
+		res.Nodes = append(res.Nodes, GraphNode{
+			Key:       key,
+			Stack:     unit.Stack().Name,
+			Name:      unit.Name(),
+			Kind:      unit.KindKey(),
+			Operation: operations[key],
+		})
+		for _, dep := range unit.Dependencies().Slice() {
+			edges[GraphEdge{
+				From:     dep.UnitKey(),
+				To:       key,
+				LinkType: linkTypeName(dep.LinkType),
+				Output:   dep.OutputName,
+			}] = true
+		}
+	}
+	for edge := range edges {
+		res.Edges = append(res.Edges, edge)
+	}
+	sort.Slice(res.Nodes, func(i, j int) bool {
+		return res.Nodes[i].Key < res.Nodes[j].Key
+	})
+	sort.Slice(res.Edges, func(i, j int) bool {
+		a, b := res.Edges[i], res.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.LinkType != b.LinkType {
+			return a.LinkType < b.LinkType
+		}
+		return a.Output < b.Output
+	})
+	return &res, nil
+}
+
+// ExportGraph renders the project dependency graph in the format (dot, mermaid or json).
+func (p *Project) ExportGraph(format string, withPlan bool) (string, error) {
+	g, err := p.NewGraphExport(withPlan)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case "dot":
+		return g.Dot(), nil
+	case "mermaid":
+		return g.Mermaid(), nil
+	case "json":
+		return utils.JSONEncodeString(g)
+	}
+	return "", fmt.Errorf("export graph: unknown format '%v', supported: %v", format, strings.Join(GraphExportFormats, ", "))
+}
+
+// stacks returns the sorted list of stack names and nodes grouped by stack.
+func (g *GraphExport) stacks() ([]string, map[string][]GraphNode) {
+	byStack := map[string][]GraphNode{}
+	for _, n := range g.Nodes {
+		byStack[n.Stack] = append(byStack[n.Stack], n)
+	}
+	names := []string{}
+	for name := range byStack {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, byStack
+}
+
+func (e *GraphEdge) label() string {
+	if e.Output == "" {
+		return e.LinkType
+	}
+	return fmt.Sprintf("%s: %s", e.LinkType, e.Output)
+}
+
+var dotOperationColors = map[string]string{
+	"Apply":      "palegreen",
+	"Update":     "khaki",
+	"Destroy":    "lightcoral",
+	"NotChanged": "white",
+}
+
+// Dot renders graph in graphviz dot format.
+func (g *GraphExport) Dot() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", g.Project)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	stackNames, byStack := g.stacks()
+	for _, stack := range stackNames {
+		fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+stack)
+		fmt.Fprintf(&b, "    label=%q;\n", stack)
+		for _, n := range byStack[stack] {
+			attrs := fmt.Sprintf("label=%q", fmt.Sprintf("%s\n(%s)", n.Name, n.Kind))
+			if color, exists := dotOperationColors[n.Operation]; exists {
+				attrs += fmt.Sprintf(", fillcolor=%q", color)
+			}
+			fmt.Fprintf(&b, "    %q [%s];\n", n.Key, attrs)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.label())
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaidIDs returns the mermaid node IDs by unit key. The IDs are index based, as the unit keys can contain
+// characters not allowed in IDs, and replacing them can make two IDs equal.
+func (g *GraphExport) mermaidIDs() map[string]string {
+	ids := map[string]string{}
+	add := func(key string) {
+		if _, exists := ids[key]; !exists {
+			ids[key] = fmt.Sprintf("u%d", len(ids))
+		}
+	}
+	for _, n := range g.Nodes {
+		add(n.Key)
+	}
+	for _, e := range g.Edges {
+		add(e.From)
+		add(e.To)
+	}
+	return ids
+}
+
+var mermaidOperationStyles = map[string]string{
+	"Apply":      "fill:#98fb98",
+	"Update":     "fill:#f0e68c",
+	"Destroy":    "fill:#f08080",
+	"NotChanged": "fill:#ffffff",
+}
+
+// Mermaid renders graph in mermaid flowchart format.
+func (g *GraphExport) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	ids := g.mermaidIDs()
+	declared := map[string]bool{}
+	stackNames, byStack := g.stacks()
+	for i, stack := range stackNames {
+		fmt.Fprintf(&b, "  subgraph s%d[\"%s\"]\n", i, stack)
+		for _, n := range byStack[stack] {
+			fmt.Fprintf(&b, "    %s[\"%s (%s)\"]\n", ids[n.Key], n.Name, n.Kind)
+			declared[n.Key] = true
+		}
+		b.WriteString("  end\n")
+	}
+	for _, e := range g.Edges {
+		// The dependency is not a project unit, show its key.
+		for _, key := range []string{e.From, e.To} {
+			if !declared[key] {
+				fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[key], key)
+				declared[key] = true
+			}
+		}
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[e.From], e.label(), ids[e.To])
+	}
+	usedOperations := map[string][]string{}
+	for _, n := range g.Nodes {
+		if n.Operation != "" {
+			usedOperations[n.Operation] = append(usedOperations[n.Operation], ids[n.Key])
+		}
+	}
+	for _, op := range []string{"Apply", "Update", "Destroy", "NotChanged"} {
+		if len(usedOperations[op]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s %s\n", op, mermaidOperationStyles[op])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(usedOperations[op], ","), op)
+	}
+	return b.String()
+}
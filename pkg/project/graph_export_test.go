@@ -0,0 +1,80 @@
+package project
+
+import "testing"
+
+// newTestGraphExport exports the graph of two units with the keys, which differ only by the characters
+// not allowed in the mermaid IDs.
+func newTestGraphExport(t *testing.T) *GraphExport {
+	p := &Project{name: "test", Units: map[string]Unit{}, Stacks: map[string]*Stack{}, UnitLinks: &UnitLinksT{}}
+	for _, name := range []string{"a-b", "a_b"} {
+		p.Stacks[name] = &Stack{Name: name, ProjectPtr: p}
+	}
+	base := newTestUnit("c", p.Stacks["a-b"])
+	app := newTestUnit("c", p.Stacks["a_b"])
+	for _, link := range []*ULinkT{
+		{Unit: base, TargetStackName: "a-b", TargetUnitName: "c", LinkType: CustomLinkType},
+		{Unit: base, TargetStackName: "a-b", TargetUnitName: "c", LinkType: OutputLinkType, OutputName: "id"},
+	} {
+		if _, err := app.deps.Set(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Units[base.Key()] = base
+	p.Units[app.Key()] = app
+	g, err := p.NewGraphExport(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Nodes[1].Operation = "Apply"
+	return g
+}
+
+func TestGraphExportDot(t *testing.T) {
+	expected := `digraph "test" {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor=white];
+  subgraph "cluster_a-b" {
+    label="a-b";
+    "a-b.c" [label="c\n(test)"];
+  }
+  subgraph "cluster_a_b" {
+    label="a_b";
+    "a_b.c" [label="c\n(test)", fillcolor="palegreen"];
+  }
+  "a-b.c" -> "a_b.c" [label="depends_on"];
+  "a-b.c" -> "a_b.c" [label="output: id"];
+}
+`
+	if res := newTestGraphExport(t).Dot(); res != expected {
+		t.Errorf("unexpected dot graph:\n%v\nexpected:\n%v", res, expected)
+	}
+}
+
+func TestGraphExportMermaid(t *testing.T) {
+	expected := `flowchart LR
+  subgraph s0["a-b"]
+    u0["c (test)"]
+  end
+  subgraph s1["a_b"]
+    u1["c (test)"]
+  end
+  u0 -->|"depends_on"| u1
+  u0 -->|"output: id"| u1
+  classDef Apply fill:#98fb98
+  class u1 Apply
+`
+	if res := newTestGraphExport(t).Mermaid(); res != expected {
+		t.Errorf("unexpected mermaid graph:\n%v\nexpected:\n%v", res, expected)
+	}
+
+	// The dependency which is not a project unit is declared by its key.
+	g := &GraphExport{Edges: []GraphEdge{{From: "infra.vpc", To: "infra.eks", LinkType: "depends_on"}}}
+	expected = `flowchart LR
+  u0["infra.vpc"]
+  u1["infra.eks"]
+  u0 -->|"depends_on"| u1
+`
+	if res := g.Mermaid(); res != expected {
+		t.Errorf("unexpected mermaid graph:\n%v\nexpected:\n%v", res, expected)
+	}
+}
@@ -0,0 +1,195 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+// newTestGraph builds the graph of the units a <- b <- c (c depends on b, b depends on a) and the
+// independent unit d, all with the same operation.
+func newTestGraph(t *testing.T, op UnitOperation) *graph {
+	stack := &Stack{Name: "infra", ProjectPtr: &Project{UnitLinks: &UnitLinksT{}}}
+	units := map[string]*testUnit{}
+	planning := &ProjectPlanningStatus{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		units[name] = newTestUnit(name, stack)
+		planning.Add(units[name], op, "", false)
+	}
+	for unit, dep := range map[string]string{"b": "a", "c": "b"} {
+		link := &ULinkT{Unit: units[dep], TargetStackName: "infra", TargetUnitName: dep, LinkType: CustomLinkType}
+		if _, err := units[unit].deps.Set(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := &graph{}
+	if err := g.BuildNew(planning, 1); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// runTestGraph executes the graph units one by one, the units from the fail list finish with error.
+// Returns the keys of the executed units in execution order.
+func runTestGraph(t *testing.T, g *graph, fail ...string) []string {
+	executed := []string{}
+	for {
+		u, finFunc, err := g.GetNextAsync(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected graph error: %v", err)
+		}
+		if u == nil {
+			return executed
+		}
+		key := u.UnitPtr.Key()
+		executed = append(executed, key)
+		var execErr error
+		for _, f := range fail {
+			if f == key {
+				execErr = fmt.Errorf("unit %v failed", key)
+			}
+		}
+		go finFunc(execErr)
+	}
+}
+
+func TestGraphKeepGoing(t *testing.T) {
+	keepGoing := config.Global.KeepGoing
+	config.Global.KeepGoing = true
+	defer func() { config.Global.KeepGoing = keepGoing }()
+
+	tests := []struct {
+		name      string
+		op        UnitOperation
+		fail      []string
+		executed  []string
+		succeeded []string
+		failed    []string
+		skipped   []string
+	}{
+		{
+			// Apply goes from dependencies to dependents: the dependents of the failed unit are skipped.
+			name:      "apply",
+			op:        Apply,
+			fail:      []string{"infra.b"},
+			executed:  []string{"infra.a", "infra.b", "infra.d"},
+			succeeded: []string{"infra.a", "infra.d"},
+			failed:    []string{"infra.b"},
+			skipped:   []string{"infra.c"},
+		},
+		{
+			name:      "apply root failed",
+			op:        Apply,
+			fail:      []string{"infra.a"},
+			executed:  []string{"infra.a", "infra.d"},
+			succeeded: []string{"infra.d"},
+			failed:    []string{"infra.a"},
+			skipped:   []string{"infra.b", "infra.c"},
+		},
+		{
+			// Destroy goes from dependents to dependencies: the dependencies of the failed unit are skipped.
+			name:      "destroy",
+			op:        Destroy,
+			fail:      []string{"infra.b"},
+			executed:  []string{"infra.b", "infra.c", "infra.d"},
+			succeeded: []string{"infra.c", "infra.d"},
+			failed:    []string{"infra.b"},
+			skipped:   []string{"infra.a"},
+		},
+		{
+			name:      "destroy leaf failed",
+			op:        Destroy,
+			fail:      []string{"infra.c"},
+			executed:  []string{"infra.c", "infra.d"},
+			succeeded: []string{"infra.d"},
+			failed:    []string{"infra.c"},
+			skipped:   []string{"infra.a", "infra.b"},
+		},
+		{
+			name:      "no failures",
+			op:        Apply,
+			executed:  []string{"infra.a", "infra.b", "infra.c", "infra.d"},
+			succeeded: []string{"infra.a", "infra.b", "infra.c", "infra.d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGraph(t, tt.op)
+			executed := runTestGraph(t, g, tt.fail...)
+			if !sameKeys(executed, tt.executed) {
+				t.Errorf("executed: expected %v, got %v", tt.executed, executed)
+			}
+			succeeded, failed, skipped := g.Summary()
+			if !reflect.DeepEqual(succeeded, tt.succeeded) {
+				t.Errorf("succeeded: expected %v, got %v", tt.succeeded, succeeded)
+			}
+			if !reflect.DeepEqual(failed, tt.failed) {
+				t.Errorf("failed: expected %v, got %v", tt.failed, failed)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped: expected %v, got %v", tt.skipped, skipped)
+			}
+			if g.HasFailed() != (len(tt.fail) > 0) {
+				t.Errorf("HasFailed: expected %v", len(tt.fail) > 0)
+			}
+			errUnits := []string{}
+			for _, u := range g.Errors() {
+				errUnits = append(errUnits, u.UnitPtr.Key())
+			}
+			if !sameKeys(errUnits, tt.failed) {
+				t.Errorf("Errors: expected %v, got %v", tt.failed, errUnits)
+			}
+		})
+	}
+}
+
+func TestGraphStopOnFailure(t *testing.T) {
+	keepGoing := config.Global.KeepGoing
+	config.Global.KeepGoing = false
+	defer func() { config.Global.KeepGoing = keepGoing }()
+
+	g := newTestGraph(t, Apply)
+	for {
+		u, finFunc, err := g.GetNextAsync(context.Background())
+		if err != nil {
+			if u == nil || u.UnitPtr.Key() != "infra.a" {
+				t.Errorf("expected the error of unit infra.a, got %v", u)
+			}
+			break
+		}
+		if u == nil {
+			t.Fatal("expected the graph error")
+		}
+		go finFunc(fmt.Errorf("unit %v failed", u.UnitPtr.Key()))
+	}
+	if !g.HasFailed() {
+		t.Error("HasFailed: expected true")
+	}
+	_, _, skipped := g.Summary()
+	if len(skipped) != 0 {
+		t.Errorf("expected no skipped units without keep going, got %v", skipped)
+	}
+}
+
+// sameKeys compares the key lists regardless of the order.
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, k := range a {
+		count[k]++
+	}
+	for _, k := range b {
+		count[k]--
+	}
+	for _, c := range count {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
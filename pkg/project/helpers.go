@@ -13,6 +13,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/shalb/cluster.dev/pkg/colors"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/shalb/cluster.dev/pkg/utils"
 )
 
@@ -35,6 +36,17 @@ func (u UnitOperation) String() string {
 	return mapperStatus[uint16(u)]
 }
 
+// Name returns operation name without colors.
+func (u UnitOperation) Name() string {
+	mapperStatus := map[uint16]string{
+		1: "Apply",
+		2: "Destroy",
+		3: "Update",
+		4: "NotChanged",
+	}
+	return mapperStatus[uint16(u)]
+}
+
 func (u UnitOperation) HasChanges() bool {
 	return u != NotChanged
 }
@@ -45,6 +57,20 @@ type UnitPlanningStatus struct {
 	Operation UnitOperation
 	IsTainted bool
 	Index     int
+	ExecErr   error
+}
+
+// Failed returns true if unit execution finished with error.
+func (u *UnitPlanningStatus) Failed() bool {
+	return u.ExecErr != nil || u.UnitPtr.ExecError() != nil
+}
+
+// ExecError returns the unit execution error: the error returned to the graph, or the error saved by the unit.
+func (u *UnitPlanningStatus) ExecError() error {
+	if u.ExecErr != nil {
+		return u.ExecErr
+	}
+	return u.UnitPtr.ExecError()
 }
 
 type ProjectPlanningStatus struct {
@@ -361,6 +387,12 @@ func ProjectsFilesExists() bool {
 
 func showPlanResults(opStatus *graph) error {
 	fmt.Println(colors.Fmt(colors.WhiteBold).Sprint("Plan results:"))
+	if config.Global.Env != "" {
+		fmt.Println(colors.Fmt(colors.WhiteBold).Sprintf("Environment: %v", config.Global.Env))
+	}
+	if len(config.Global.Targets) > 0 {
+		fmt.Println(colors.Fmt(colors.Yellow).Sprintf("Targeting is in effect: %v. Only the targeted units and the units bound with them will be processed.", strings.Join(config.Global.Targets, ", ")))
+	}
 
 	if opStatus.Len() == 0 {
 		fmt.Println(colors.Fmt(colors.WhiteBold).Sprint("No changes, nothing to do."))
@@ -376,19 +408,19 @@ func showPlanResults(opStatus *graph) error {
 		log.Infof(colors.Fmt(colors.LightWhiteBold).Sprintf("Planning unit '%v':", unit.UnitPtr.Key()))
 		switch unit.Operation {
 		case Apply:
-			fmt.Printf("%v\n", unit.Diff)
+			fmt.Printf("%v\n", sensitive.MaskString(unit.Diff))
 			if len(deployString) != 0 {
 				deployString += "\n"
 			}
 			deployString += RenderUnitPlanningString(unit)
 		case Update:
-			fmt.Printf("%v\n", unit.Diff)
+			fmt.Printf("%v\n", sensitive.MaskString(unit.Diff))
 			if len(updateString) != 0 {
 				updateString += "\n"
 			}
 			updateString += RenderUnitPlanningString(unit)
 		case Destroy:
-			fmt.Printf("%v\n", unit.Diff)
+			fmt.Printf("%v\n", sensitive.MaskString(unit.Diff))
 			if len(destroyString) != 0 {
 				destroyString += "\n"
 			}
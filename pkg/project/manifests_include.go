@@ -0,0 +1,128 @@
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// readIncludePatterns reads the 'include' list from the raw project config. The list is needed before
+// the project config is parsed, to read the manifests from the nested directories.
+func readIncludePatterns(configData []byte) ([]string, error) {
+	conf := struct {
+		Include []string `yaml:"include"`
+	}{}
+	if err := yaml.Unmarshal(configData, &conf); err != nil {
+		return nil, fmt.Errorf("parsing project config: %v", utils.ResolveYamlError(configData, err))
+	}
+	for _, pattern := range conf.Include {
+		if pattern == "" || filepath.IsAbs(pattern) || strings.HasPrefix(path.Clean(filepath.ToSlash(pattern)), "../") {
+			return nil, fmt.Errorf("project config: include '%v': pattern should be relative to the project dir", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("project config: include '%v': %w", pattern, err)
+		}
+	}
+	return conf.Include, nil
+}
+
+// includedManifests returns the sorted list of the yaml files in the project dir which match any of
+// the include patterns. '**' matches any number of directories. The cdev working dir and the
+// environments dir are skipped.
+func includedManifests(patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	root := config.Global.WorkingDir
+	matched := map[string]bool{}
+	found := map[string]bool{}
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, file)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || rel == envsDirName) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		for _, pattern := range patterns {
+			if matchGlob(path.Clean(filepath.ToSlash(pattern)), rel) {
+				found[file] = true
+				matched[pattern] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading included manifests: %w", err)
+	}
+	for _, pattern := range patterns {
+		if !matched[pattern] {
+			log.Warnf("Project config: include '%v' does not match any file", pattern)
+		}
+	}
+	files := make([]string, 0, len(found))
+	for file := range found {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// matchGlob reports whether the slash separated path matches the pattern. Pattern elements are matched
+// by path.Match, '**' element matches zero or more directories.
+func matchGlob(pattern, name string) bool {
+	return matchGlobParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// checkDuplicateObject returns an error if the object with the same kind and name is already defined
+// in the base manifests.
+func (p *Project) checkDuplicateObject(objKind string, obj map[string]interface{}, filename string) error {
+	name, ok := obj["name"].(string)
+	if !ok {
+		return nil
+	}
+	for _, existing := range p.objects[objKind] {
+		if existing.data["name"] == name && !p.isEnvFile(existing.filename) {
+			first, _ := filepath.Rel(config.Global.WorkingDir, existing.filename)
+			second, _ := filepath.Rel(config.Global.WorkingDir, filename)
+			return fmt.Errorf("duplicate %v name '%v' in '%v' and '%v'", objKind, name, first, second)
+		}
+	}
+	return nil
+}
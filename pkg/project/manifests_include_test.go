@@ -0,0 +1,117 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"stacks/*.yaml", "stacks/infra.yaml", true},
+		{"stacks/*.yaml", "stacks/dev/infra.yaml", false},
+		{"stacks/**/*.yaml", "stacks/infra.yaml", true},
+		{"stacks/**/*.yaml", "stacks/dev/eu/infra.yaml", true},
+		{"**/*.yml", "a/b/c.yml", true},
+		{"**/*.yml", "a/b/c.yaml", false},
+		{"stacks/**", "stacks/dev/infra.yaml", true},
+		{"backends/*.yaml", "stacks/backend.yaml", false},
+	}
+	for _, c := range cases {
+		if matchGlob(c.pattern, c.name) != c.match {
+			t.Errorf("matchGlob(%q, %q): expected %v", c.pattern, c.name, c.match)
+		}
+	}
+}
+
+func TestIncludedManifests(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"project.yaml", "stacks/b.yaml", "stacks/a/infra.yml", "stacks/readme.md", "envs/prod/stacks/x.yaml", ".cluster.dev/stacks/y.yaml"} {
+		file := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("kind: Stack\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd := config.Global.WorkingDir
+	defer func() { config.Global.WorkingDir = wd }()
+	config.Global.WorkingDir = dir
+
+	files, err := includedManifests([]string{"stacks/**/*.y*ml", "**/stacks/*.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(dir, "stacks/a/infra.yml"), filepath.Join(dir, "stacks/b.yaml")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files: %v", files)
+	}
+	for _, conf := range []string{"include: [/etc/*.yaml]", "include: ['../*.yaml']", "include: ['[']"} {
+		if _, err := readIncludePatterns([]byte(conf)); err == nil {
+			t.Errorf("expected error for %v", conf)
+		}
+	}
+}
+
+func TestDuplicateObjects(t *testing.T) {
+	wd := config.Global.WorkingDir
+	defer func() { config.Global.WorkingDir = wd }()
+	config.Global.WorkingDir = "/prj"
+
+	p := &Project{objects: map[string][]ObjectData{}}
+	if err := p.readObjects([]byte("name: infra\nkind: Stack\n"), "/prj/stacks/a.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.readObjects([]byte("name: infra\nkind: Backend\n"), "/prj/backend.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	err := p.readObjects([]byte("name: infra\nkind: Stack\n"), "/prj/stacks/dev/b.yaml")
+	if err == nil || !strings.Contains(err.Error(), "'stacks/a.yaml' and 'stacks/dev/b.yaml'") {
+		t.Errorf("expected duplicate error with both files, got %v", err)
+	}
+}
+
+// testSecretDriver reads the plain text secrets with the data in the 'spec' field.
+type testSecretDriver struct{}
+
+func (d *testSecretDriver) Read(data []byte) (string, interface{}, error) {
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return "", nil, err
+	}
+	return obj["name"].(string), obj["spec"], nil
+}
+
+func (d *testSecretDriver) Key() string                    { return "test" }
+func (d *testSecretDriver) Edit(Secret) error              { return nil }
+func (d *testSecretDriver) Create(map[string][]byte) error { return nil }
+
+func TestDuplicateSecrets(t *testing.T) {
+	wd := config.Global.WorkingDir
+	defer func() { config.Global.WorkingDir = wd }()
+	config.Global.WorkingDir = "/prj"
+	SecretDriversMap["test"] = &testSecretDriver{}
+	defer delete(SecretDriversMap, "test")
+
+	p := &Project{
+		secrets:    map[string]Secret{},
+		configData: map[string]interface{}{},
+		objectsFiles: map[string][]byte{
+			"/prj/secrets/a.yaml":     []byte("name: token\nkind: Secret\ndriver: test\nspec:\n  value: a\n"),
+			"/prj/secrets/dev/b.yaml": []byte("name: token\nkind: Secret\ndriver: test\nspec:\n  value: b\n"),
+		},
+	}
+	err := p.readSecrets()
+	if err == nil || !strings.Contains(err.Error(), "duplicated secret name 'token' in 'secrets/a.yaml' and 'secrets/dev/b.yaml'") {
+		t.Errorf("expected duplicate secret error with both files, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// PlanJSONFormatVersion version of machine-readable plan document.
+const PlanJSONFormatVersion = 1
+
+// PlanJSON describes machine-readable plan output (cdev plan --json).
+type PlanJSON struct {
+	FormatVersion int            `json:"format_version"`
+	Project       string         `json:"project"`
+	Env           string         `json:"env,omitempty"`
+	HasChanges    bool           `json:"has_changes"`
+	Targets       []string       `json:"targets,omitempty"`
+	Units         []UnitPlanJSON `json:"units"`
+}
+
+// UnitPlanJSON describes planned operation of one unit.
+type UnitPlanJSON struct {
+	Key          string        `json:"key"`
+	Stack        string        `json:"stack"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	Operation    string        `json:"operation"`
+	Tainted      bool          `json:"tainted"`
+	Index        int           `json:"index"`
+	Dependencies []string      `json:"dependencies"`
+	Diff         *UnitDiffJSON `json:"diff,omitempty"`
+}
+
+// UnitDiffJSON structured unit diff: the unit data before and after the operation.
+type UnitDiffJSON struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// NewPlanJSON creates machine-readable representation of the planning graph.
+func (p *Project) NewPlanJSON(planGraph *graph) *PlanJSON {
+	res := PlanJSON{
+		FormatVersion: PlanJSONFormatVersion,
+		Project:       p.Name(),
+		Env:           p.Env(),
+		HasChanges:    planGraph.HasChanges(),
+		Targets:       config.Global.Targets,
+		Units:         []UnitPlanJSON{},
+	}
+	for _, us := range planGraph.IndexedSlice() {
+		deps := []string{}
+		for key := range us.UnitPtr.Dependencies().UniqUnits() {
+			deps = append(deps, key)
+		}
+		sort.Strings(deps)
+		unitJSON := UnitPlanJSON{
+			Key:          us.UnitPtr.Key(),
+			Stack:        us.UnitPtr.Stack().Name,
+			Name:         us.UnitPtr.Name(),
+			Kind:         us.UnitPtr.KindKey(),
+			Operation:    us.Operation.Name(),
+			Tainted:      us.IsTainted,
+			Index:        us.Index,
+			Dependencies: deps,
+		}
+		switch us.Operation {
+		case Apply:
+			unitJSON.Diff = &UnitDiffJSON{After: us.UnitPtr.GetDiffData()}
+		case Update:
+			unitJSON.Diff = &UnitDiffJSON{After: us.UnitPtr.GetDiffData()}
+			if p.OwnState != nil {
+				if stateUnit, exists := p.OwnState.Units[us.UnitPtr.Key()]; exists {
+					unitJSON.Diff.Before = stateUnit.GetDiffData()
+				}
+			}
+		case Destroy:
+			unitJSON.Diff = &UnitDiffJSON{Before: us.UnitPtr.GetDiffData()}
+		}
+		res.Units = append(res.Units, unitJSON)
+	}
+	return &res
+}
+
+// PrintPlanJSON prints machine-readable plan to stdout.
+func (p *Project) PrintPlanJSON(planGraph *graph) error {
+	data, err := utils.JSONEncodeString(p.NewPlanJSON(planGraph))
+	if err != nil {
+		return fmt.Errorf("print plan: %w", err)
+	}
+	fmt.Print(sensitive.MaskString(data))
+	return nil
+}
+
+// PlanExitCode returns the plan command exit code for the --detailed-exitcode option: 0 - no changes, 1 - error,
+// 2 - there are changes.
+func (p *Project) PlanExitCode(planGraph *graph, err error) int {
+	if err != nil {
+		return 1
+	}
+	if planGraph.HasChanges() {
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package project
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+func TestPlanJSON(t *testing.T) {
+	p, _ := newPlanTestProject(t)
+	planGraph, err := p.buildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.NewPlanJSON(planGraph)
+	if res.FormatVersion != PlanJSONFormatVersion || res.Project != "plan-test" || !res.HasChanges {
+		t.Errorf("unexpected plan: %+v", res)
+	}
+	expected := []UnitPlanJSON{
+		{
+			Key:          "infra.a",
+			Stack:        "infra",
+			Name:         "a",
+			Kind:         testUnitType,
+			Operation:    "Apply",
+			Index:        0,
+			Dependencies: []string{},
+			Diff:         &UnitDiffJSON{After: map[string]interface{}{"size": 1}},
+		},
+		{
+			Key:          "infra.b",
+			Stack:        "infra",
+			Name:         "b",
+			Kind:         testUnitType,
+			Operation:    "Apply",
+			Index:        1,
+			Dependencies: []string{"infra.a"},
+			Diff:         &UnitDiffJSON{After: map[string]interface{}{"size": 2}},
+		},
+	}
+	if !reflect.DeepEqual(res.Units, expected) {
+		t.Errorf("unexpected units:\nexpected %+v\ngot %+v", expected, res.Units)
+	}
+}
+
+func TestPlanExitCode(t *testing.T) {
+	defer func(targets []string) { config.Global.Targets = targets }(config.Global.Targets)
+
+	tests := []struct {
+		name     string
+		change   func(p *Project)
+		expected int
+	}{
+		{
+			name:     "changes",
+			change:   func(p *Project) {},
+			expected: 2,
+		},
+		{
+			name: "no changes",
+			change: func(p *Project) {
+				for key, u := range p.Units {
+					p.OwnState.Units[key] = u
+				}
+			},
+			expected: 0,
+		},
+		{
+			name: "error",
+			change: func(p *Project) {
+				config.Global.Targets = []string{"infra.nope"}
+			},
+			expected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Global.Targets = nil
+			p, _ := newPlanTestProject(t)
+			tt.change(p)
+			planGraph, err := p.buildPlan()
+			if code := p.PlanExitCode(planGraph, err); code != tt.expected {
+				t.Errorf("expected exit code %v, got %v (plan error: %v)", tt.expected, code, err)
+			}
+			if err == nil && p.NewPlanJSON(planGraph).HasChanges != (tt.expected == 2) {
+				t.Errorf("unexpected has_changes in the plan")
+			}
+		})
+	}
+	if code := (&Project{}).PlanExitCode(nil, fmt.Errorf("load project")); code != 1 {
+		t.Errorf("expected exit code 1 on error, got %v", code)
+	}
+}
@@ -0,0 +1,243 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/colors"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// PlanFileVersion version of the saved plan file format.
+const PlanFileVersion = 1
+
+// PlanFile describes the saved project plan, which can be applied later with 'cdev apply <planfile>'.
+// The --var and --var-file overrides are saved yaml encoded in Variables, the var files are not read on apply.
+type PlanFile struct {
+	FormatVersion int            `json:"format_version"`
+	CdevVersion   string         `json:"cdev_version"`
+	ProjectName   string         `json:"project_name"`
+	ProjectUUID   string         `json:"project_uuid"`
+	StateHash     string         `json:"state_hash"`
+	IgnoreState   bool           `json:"ignore_state,omitempty"`
+	Targets       []string       `json:"targets,omitempty"`
+	Variables     string         `json:"variables,omitempty"`
+	Env           string         `json:"env,omitempty"`
+	Units         []PlanFileUnit `json:"units"`
+}
+
+// PlanFileUnit describes the planned operation of one unit.
+type PlanFileUnit struct {
+	Key          string `json:"key"`
+	Operation    string `json:"operation"`
+	Index        int    `json:"index"`
+	Tainted      bool   `json:"tainted,omitempty"`
+	DiffDataHash string `json:"diff_data_hash"`
+}
+
+func diffDataHash(u Unit) (string, error) {
+	data, err := utils.JSONEncode(u.GetDiffData())
+	if err != nil {
+		return "", fmt.Errorf("unit '%v': encode diff data: %w", u.Key(), err)
+	}
+	return utils.Md5(string(data)), nil
+}
+
+// newPlanFile creates plan file data from the planning graph.
+func (p *Project) newPlanFile(planGraph *graph) (*PlanFile, error) {
+	stateHash, err := p.currentStateHash()
+	if err != nil {
+		return nil, err
+	}
+	variables := ""
+	if len(p.variablesOverrides) > 0 {
+		data, err := yaml.Marshal(p.variablesOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("encode variables overrides: %w", err)
+		}
+		variables = string(data)
+	}
+	pf := PlanFile{
+		FormatVersion: PlanFileVersion,
+		CdevVersion:   config.Global.Version,
+		ProjectName:   p.Name(),
+		ProjectUUID:   p.UUID,
+		StateHash:     stateHash,
+		IgnoreState:   config.Global.IgnoreState,
+		Targets:       config.Global.Targets,
+		Variables:     variables,
+		Env:           p.Env(),
+		Units:         []PlanFileUnit{},
+	}
+	for _, us := range planGraph.IndexedSlice() {
+		hash, err := diffDataHash(us.UnitPtr)
+		if err != nil {
+			return nil, err
+		}
+		pf.Units = append(pf.Units, PlanFileUnit{
+			Key:          us.UnitPtr.Key(),
+			Operation:    us.Operation.Name(),
+			Index:        us.Index,
+			Tainted:      us.IsTainted,
+			DiffDataHash: hash,
+		})
+	}
+	return &pf, nil
+}
+
+// currentStateHash returns hash of the state data loaded for planning.
+func (p *Project) currentStateHash() (string, error) {
+	if p.stateHash != "" {
+		return p.stateHash, nil
+	}
+	stateData, err := p.GetState()
+	if err != nil {
+		return "", fmt.Errorf("state hash: %w", err)
+	}
+	return utils.Md5(string(stateData)), nil
+}
+
+// SavePlan writes planning graph to the plan file.
+func (p *Project) SavePlan(planGraph *graph, fileName string) error {
+	pf, err := p.newPlanFile(planGraph)
+	if err != nil {
+		return fmt.Errorf("save plan: %w", err)
+	}
+	data, err := utils.JSONEncode(pf)
+	if err != nil {
+		return fmt.Errorf("save plan: %w", err)
+	}
+	log.Infof("Saving plan to file: %v", fileName)
+	return os.WriteFile(fileName, data, 0600)
+}
+
+// ReadPlanFile reads and parses saved plan file.
+func ReadPlanFile(fileName string) (*PlanFile, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read plan file: %w", err)
+	}
+	pf := PlanFile{}
+	err = utils.JSONDecode(data, &pf)
+	if err != nil {
+		return nil, fmt.Errorf("read plan file '%v': %w", fileName, err)
+	}
+	if pf.FormatVersion != PlanFileVersion {
+		return nil, fmt.Errorf("read plan file '%v': unsupported format version %v", fileName, pf.FormatVersion)
+	}
+	return &pf, nil
+}
+
+// SetGlobalConfig sets the global options, which was used to create the plan. Should be called before the project loading.
+func (pf *PlanFile) SetGlobalConfig() error {
+	if len(config.Global.Vars) > 0 || len(config.Global.VarFiles) > 0 {
+		return fmt.Errorf("the variables overrides are saved in the plan file, --var and --var-file options can't be used with it")
+	}
+	if len(config.Global.Targets) > 0 && !sameTargets(config.Global.Targets, pf.Targets) {
+		return fmt.Errorf("the plan was created with targets %v, --target options %v differ", pf.Targets, config.Global.Targets)
+	}
+	if config.Global.IgnoreState && !pf.IgnoreState {
+		return fmt.Errorf("the plan was created without --ignore-state option")
+	}
+	if config.Global.Env != "" && config.Global.Env != pf.Env {
+		return fmt.Errorf("the plan was created for environment '%v', --env '%v' differs", pf.Env, config.Global.Env)
+	}
+	config.Global.IgnoreState = pf.IgnoreState
+	config.Global.Targets = pf.Targets
+	config.Global.VarsData = pf.Variables
+	config.Global.Env = pf.Env
+	return nil
+}
+
+// sameTargets compares the targets lists regardless of the order.
+func sameTargets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyPlan checks that the saved plan is still actual and applies it without interactive approval.
+func (p *Project) ApplyPlan(ctx context.Context, pf *PlanFile) error {
+	if pf.ProjectName != p.Name() {
+		return fmt.Errorf("apply plan: plan was created for project '%v', current project is '%v'", pf.ProjectName, p.Name())
+	}
+	// Read the state again after locking, to be sure it was not changed after the project loading.
+	stateData, err := p.GetState()
+	if err != nil {
+		return fmt.Errorf("apply plan: %w", err)
+	}
+	stateHash := utils.Md5(string(stateData))
+	if stateHash != pf.StateHash || (p.stateHash != "" && p.stateHash != pf.StateHash) {
+		return fmt.Errorf("apply plan: the state was changed after the plan was created, run 'cdev plan' again")
+	}
+	if len(stateData) == 0 {
+		// New project, the UUID was generated while planning.
+		p.UUID = pf.ProjectUUID
+		p.OwnState.UUID = pf.ProjectUUID
+	} else if pf.ProjectUUID != p.UUID {
+		return fmt.Errorf("apply plan: project UUID mismatch: plan '%v', state '%v'", pf.ProjectUUID, p.UUID)
+	}
+	log.Infof(colors.Fmt(colors.LightWhiteBold).Sprintf("Checking units in state"))
+	applyGraph, err := p.buildPlan()
+	if err != nil {
+		return err
+	}
+	showPlanResults(applyGraph)
+	current, err := p.newPlanFile(applyGraph)
+	if err != nil {
+		return fmt.Errorf("apply plan: %w", err)
+	}
+	if diffs := pf.compareUnits(current); len(diffs) > 0 {
+		return fmt.Errorf("apply plan: the project was changed after the plan was created, run 'cdev plan' again:\n%v", strings.Join(diffs, "\n"))
+	}
+	if !applyGraph.planningUnits.HasChanges() {
+		return nil
+	}
+	return p.runApply(ctx, applyGraph)
+}
+
+// compareUnits returns the list of differences between planned units.
+func (pf *PlanFile) compareUnits(current *PlanFile) (res []string) {
+	saved := map[string]PlanFileUnit{}
+	for _, u := range pf.Units {
+		saved[u.Key] = u
+	}
+	for _, cur := range current.Units {
+		su, exists := saved[cur.Key]
+		if !exists {
+			res = append(res, fmt.Sprintf("unit '%v' is not in the saved plan", cur.Key))
+			continue
+		}
+		delete(saved, cur.Key)
+		if su.Operation != cur.Operation {
+			res = append(res, fmt.Sprintf("unit '%v': planned operation '%v', actual '%v'", cur.Key, su.Operation, cur.Operation))
+		}
+		if su.Tainted != cur.Tainted {
+			res = append(res, fmt.Sprintf("unit '%v': tainted flag changed", cur.Key))
+		}
+		if su.DiffDataHash != cur.DiffDataHash {
+			res = append(res, fmt.Sprintf("unit '%v': unit configuration changed", cur.Key))
+		}
+	}
+	for key := range saved {
+		res = append(res, fmt.Sprintf("unit '%v' is missing in the current plan", key))
+	}
+	sort.Strings(res)
+	return
+}
@@ -0,0 +1,260 @@
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// stateBackend test backend with the state in memory.
+type stateBackend struct {
+	offlineBackend
+	state string
+}
+
+func (b *stateBackend) ReadState() (string, error) {
+	return b.state, nil
+}
+
+// newPlanTestProject creates the project with the units a and b (b depends on a) and the empty state.
+func newPlanTestProject(t *testing.T) (*Project, *stateBackend) {
+	bk := &stateBackend{offlineBackend: offlineBackend{name: "bk", provider: "test"}}
+	p := &Project{
+		name:             "plan-test",
+		UUID:             "1234",
+		Units:            map[string]Unit{},
+		Stacks:           map[string]*Stack{},
+		UnitLinks:        &UnitLinksT{},
+		Backends:         map[string]Backend{"bk": bk},
+		StateBackendName: "bk",
+	}
+	stack := &Stack{Name: "infra", ProjectPtr: p}
+	p.Stacks["infra"] = stack
+	a := newTestUnit("a", stack)
+	a.data = map[string]interface{}{"size": 1}
+	b := newTestUnit("b", stack)
+	b.data = map[string]interface{}{"size": 2}
+	if _, err := b.deps.Set(&ULinkT{Unit: a, TargetStackName: "infra", TargetUnitName: "a", LinkType: CustomLinkType}); err != nil {
+		t.Fatal(err)
+	}
+	p.Units[a.Key()] = a
+	p.Units[b.Key()] = b
+	p.OwnState = p.NewEmptyState()
+	return p, bk
+}
+
+// savePlan plans the project and saves the plan to the temporary file.
+func savePlan(t *testing.T, p *Project) string {
+	planGraph, err := p.buildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "project.plan")
+	if err := p.SavePlan(planGraph, fileName); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	p, _ := newPlanTestProject(t)
+	fileName := savePlan(t, p)
+	pf, err := ReadPlanFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planGraph, err := p.buildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := p.newPlanFile(planGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pf, expected) {
+		t.Errorf("plan file changed after save and read:\nexpected %+v\ngot %+v", expected, pf)
+	}
+	if pf.ProjectName != "plan-test" || pf.ProjectUUID != "1234" {
+		t.Errorf("unexpected project in plan: '%v', '%v'", pf.ProjectName, pf.ProjectUUID)
+	}
+	units := []string{}
+	for _, u := range pf.Units {
+		units = append(units, u.Key+":"+u.Operation)
+	}
+	if strings.Join(units, ",") != "infra.a:Apply,infra.b:Apply" {
+		t.Errorf("unexpected planned units: %v", units)
+	}
+	if diffs := pf.compareUnits(expected); len(diffs) > 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+}
+
+func TestReadPlanFileErrors(t *testing.T) {
+	if _, err := ReadPlanFile(filepath.Join(t.TempDir(), "nope")); err == nil {
+		t.Error("expected error for not existing file")
+	}
+	p, _ := newPlanTestProject(t)
+	planGraph, err := p.buildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := p.newPlanFile(planGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.FormatVersion = PlanFileVersion + 1
+	fileName := filepath.Join(t.TempDir(), "project.plan")
+	data, err := utils.JSONEncode(pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadPlanFile(fileName)
+	if err == nil || !strings.Contains(err.Error(), "unsupported format version") {
+		t.Errorf("expected format version error, got %v", err)
+	}
+}
+
+func TestApplyPlanRejectsStalePlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(p *Project, bk *stateBackend)
+		errMsg string
+	}{
+		{
+			name: "state changed",
+			change: func(p *Project, bk *stateBackend) {
+				bk.state = `{"version": "v0.0.0"}`
+			},
+			errMsg: "the state was changed after the plan was created",
+		},
+		{
+			name: "other project",
+			change: func(p *Project, bk *stateBackend) {
+				p.name = "other"
+			},
+			errMsg: "plan was created for project 'plan-test'",
+		},
+		{
+			name: "unit added",
+			change: func(p *Project, bk *stateBackend) {
+				c := newTestUnit("c", p.Stacks["infra"])
+				p.Units[c.Key()] = c
+			},
+			errMsg: "unit 'infra.c' is not in the saved plan",
+		},
+		{
+			name: "unit removed",
+			change: func(p *Project, bk *stateBackend) {
+				delete(p.Units, "infra.b")
+			},
+			errMsg: "unit 'infra.b' is missing in the current plan",
+		},
+		{
+			name: "unit changed",
+			change: func(p *Project, bk *stateBackend) {
+				p.Units["infra.a"].(*testUnit).data["size"] = 3
+			},
+			errMsg: "unit 'infra.a': unit configuration changed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, bk := newPlanTestProject(t)
+			pf, err := ReadPlanFile(savePlan(t, p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(p, bk)
+			err = p.ApplyPlan(context.Background(), pf)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error with '%v', got '%v'", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestPlanFileVariables(t *testing.T) {
+	defer func(vars, files []string, data string) {
+		config.Global.Vars, config.Global.VarFiles, config.Global.VarsData = vars, files, data
+	}(config.Global.Vars, config.Global.VarFiles, config.Global.VarsData)
+	config.Global.Vars = []string{"infra.replicas=3", "infra.image.tag=v1.10"}
+	config.Global.VarFiles = nil
+
+	p, _ := newPlanTestProject(t)
+	if err := p.readVariablesOverrides(); err != nil {
+		t.Fatal(err)
+	}
+	pf, err := ReadPlanFile(savePlan(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pf.SetGlobalConfig(); err == nil || !strings.Contains(err.Error(), "--var and --var-file") {
+		t.Fatalf("expected error for --var with the plan file, got %v", err)
+	}
+
+	// The overrides are taken from the plan, the var files are not read again.
+	config.Global.Vars = nil
+	config.Global.VarFiles = nil
+	if err := pf.SetGlobalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	config.Global.VarFiles = []string{filepath.Join(t.TempDir(), "nope.yaml")}
+	applyProject := &Project{}
+	if err := applyProject.readVariablesOverrides(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applyProject.variablesOverrides, p.variablesOverrides) {
+		t.Errorf("overrides changed after save and read:\nexpected %v\ngot %v", p.variablesOverrides, applyProject.variablesOverrides)
+	}
+}
+
+func TestPlanFileGlobalOptions(t *testing.T) {
+	defer func(targets []string, ignoreState bool, env string) {
+		config.Global.Targets, config.Global.IgnoreState, config.Global.Env = targets, ignoreState, env
+	}(config.Global.Targets, config.Global.IgnoreState, config.Global.Env)
+
+	pf := &PlanFile{Targets: []string{"infra.a", "infra.b"}, Env: "dev"}
+	tests := []struct {
+		name        string
+		targets     []string
+		ignoreState bool
+		env         string
+		errMsg      string
+	}{
+		{name: "not set"},
+		{name: "same", targets: []string{"infra.b", "infra.a"}, env: "dev"},
+		{name: "other targets", targets: []string{"infra.a"}, errMsg: "--target options [infra.a] differ"},
+		{name: "ignore state", ignoreState: true, errMsg: "without --ignore-state"},
+		{name: "other env", env: "prod", errMsg: "--env 'prod' differs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Global.Targets, config.Global.IgnoreState, config.Global.Env = tt.targets, tt.ignoreState, tt.env
+			err := pf.SetGlobalConfig()
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(config.Global.Targets, pf.Targets) || config.Global.Env != pf.Env || config.Global.IgnoreState {
+					t.Errorf("the plan options are not set: %v, %v, %v", config.Global.Targets, config.Global.Env, config.Global.IgnoreState)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error with '%v', got '%v'", tt.errMsg, err)
+			}
+		})
+	}
+}
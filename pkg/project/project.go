@@ -5,12 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/apex/log"
 	"github.com/gookit/color"
 	"github.com/olekukonko/tablewriter"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/shalb/cluster.dev/pkg/utils"
 	"gopkg.in/yaml.v3"
 )
@@ -53,8 +56,25 @@ type Project struct {
 	OwnState            *StateProject
 	UUID                string
 	ProcessedUnitsCount uint
-	HupUnlockChan       chan os.Signal
 	NewVersionMessage   string
+	stateHash           string
+	stateHistoryLimit   int
+	// stateMigrated the remote state was migrated from the older schema version and must be backed up before the first write.
+	stateMigrated bool
+	// env the active environment name.
+	env               string
+	envConfigDataFile []byte
+	// envFiles the environment overlay files, which are merged into the base objects.
+	envFiles map[string]bool
+	// variablesOverrides the variables set by --var and --var-file options, by stack name or 'project'.
+	variablesOverrides map[string]interface{}
+	// stateEncryption the state encryption config, nil if the state is not encrypted.
+	stateEncryption       *stateEncryptionSpec
+	stateKeyProviderCache StateKeyProvider
+	// offline the backends are not configured, used by the project validation.
+	offline bool
+	// unresolvedLinks the references to not existing units, collected by the project validation.
+	unresolvedLinks []string
 }
 
 // NewEmptyProject creates new empty project. The configuration will not be loaded.
@@ -62,6 +82,7 @@ func NewEmptyProject() *Project {
 
 	project := &Project{
 		SessionId:           utils.Md5(utils.RandString(64)),
+		stateHistoryLimit:   defaultStateHistoryLimit,
 		Stacks:              make(map[string]*Stack),
 		Units:               make(map[string]Unit),
 		Backends:            make(map[string]Backend),
@@ -76,6 +97,13 @@ func NewEmptyProject() *Project {
 		},
 		CodeCacheDir: config.Global.CacheDir,
 	}
+	if config.Global.Env != "" {
+		// Units code of each environment has its own terraform backend config.
+		project.CodeCacheDir = filepath.Join(config.Global.WorkDir, envsDirName, config.Global.Env, "cache")
+	}
+	if config.Global.Offline {
+		return project
+	}
 	log.Info("Checking for newer releases...")
 	err := utils.DiscoverCdevLastRelease()
 	if err != nil {
@@ -100,6 +128,10 @@ func LoadProjectBase() (*Project, error) {
 	if err != nil {
 		return nil, fmt.Errorf("loading project: %w", err)
 	}
+	err = project.readVariablesOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("loading project: %w", err)
+	}
 	err = project.parseProjectConfig()
 	if err != nil {
 		return nil, fmt.Errorf("loading project: %w", err)
@@ -117,7 +149,9 @@ func LoadProjectFull() (*Project, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load base configuration: %w", err)
 	}
-	for filename, cnf := range project.objectsFiles {
+	// Environment overlays are read after the base objects to be merged into them.
+	for _, filename := range project.sortedObjectsFiles() {
+		cnf := project.objectsFiles[filename]
 		templatedConf, isWarn, err := project.TemplateTry(cnf, filename)
 		if project.fileIsSecret(filename) {
 			// Skip secrets, which loaded in LoadProjectBase().
@@ -180,6 +214,15 @@ func (p *Project) readObjects(objData []byte, filename string) error {
 		if !ok {
 			return fmt.Errorf("object must contain field 'kind'")
 		}
+		if p.isEnvFile(filename) {
+			if err := p.mergeEnvObject(objKind, obj, filename); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.checkDuplicateObject(objKind, obj, filename); err != nil {
+			return err
+		}
 		if _, exists := p.objects[objKind]; !exists {
 			p.objects[objKind] = []ObjectData{}
 		}
@@ -255,7 +298,7 @@ func (p *Project) MkBuildDir() error {
 	}
 	log.Debugf("Creates code directory: './%v'", relPath)
 	if _, err := os.Stat(p.CodeCacheDir); os.IsNotExist(err) {
-		err := os.Mkdir(p.CodeCacheDir, 0755)
+		err := os.MkdirAll(p.CodeCacheDir, 0755)
 		if err != nil {
 			return err
 		}
@@ -296,9 +339,14 @@ func (p *Project) Name() string {
 func (p *Project) PrintInfo() error {
 	fmt.Println("Project:")
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Name", "Stacks count", "Units count", "Backends count", "Secrets count"})
+	table.SetHeader([]string{"Name", "Environment", "Stacks count", "Units count", "Backends count", "Secrets count"})
+	env := p.env
+	if env == "" {
+		env = "-"
+	}
 	table.Append([]string{
 		p.name,
+		env,
 		fmt.Sprintf("%v", len(p.Stacks)),
 		fmt.Sprintf("%v", len(p.Units)),
 		fmt.Sprintf("%v", len(p.Backends)),
@@ -325,6 +373,11 @@ func (p *Project) PrintInfo() error {
 	}
 	table.Render()
 
+	err := p.printVariables()
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("units:")
 	table = tablewriter.NewWriter(os.Stdout)
 	table.SetRowLine(true)
@@ -352,8 +405,42 @@ func (p *Project) PrintInfo() error {
 	return nil
 }
 
+// printVariables prints the effective variables of the project and stacks, with the --var and --var-file overrides applied.
+func (p *Project) printVariables() error {
+	fmt.Println("Variables:")
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Name", "Variables"})
+	rows := map[string]interface{}{}
+	if prjConf, ok := p.configData["project"].(map[string]interface{}); ok && prjConf["variables"] != nil {
+		rows[projectOverridesKey] = prjConf["variables"]
+	}
+	for name, stack := range p.Stacks {
+		rows[name] = stack.Variables
+	}
+	names := make([]string, 0, len(rows))
+	for name := range rows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars, err := yaml.Marshal(sensitive.MaskData(rows[name]))
+		if err != nil {
+			return fmt.Errorf("print variables: %w", err)
+		}
+		table.Append([]string{name, strings.TrimSpace(string(vars))})
+	}
+	table.Render()
+	return nil
+}
+
 func (p *Project) PrintOutputs() (err error) {
 	for _, o := range p.RuntimeDataset.PrintersOutputs {
+		if sensitiveOutputs, err := utils.TerraformJSONSensitiveOutputs(o.Output); err == nil {
+			sensitive.AddData(sensitiveOutputs)
+		}
 		if len(o.Output) > 0 {
 			var output string
 			if config.Global.OutputJSON {
@@ -26,6 +26,10 @@ func (p *Project) parseProjectConfig() error {
 	if err != nil {
 		return fmt.Errorf("parsing project config: %v", utils.ResolveYamlError(p.configDataFile, err))
 	}
+	err = p.mergeEnvProjectConfig(prjConfParsed)
+	if err != nil {
+		return err
+	}
 	if name, ok := prjConfParsed["name"].(string); !ok {
 		return fmt.Errorf("error in project config: name is required")
 	} else {
@@ -48,6 +52,32 @@ func (p *Project) parseProjectConfig() error {
 		return fmt.Errorf("error in project config: backend is not defined. To use default local backend, set 'backend: default' option")
 	}
 
+	if limit, exists := prjConfParsed["state_history"]; exists {
+		limitInt, ok := limit.(int)
+		if !ok || limitInt < 0 {
+			return fmt.Errorf("error in project config: 'state_history' should be a non-negative number")
+		}
+		p.stateHistoryLimit = limitInt
+	}
+
+	if encryption, exists := prjConfParsed["state_encryption"]; exists {
+		p.stateEncryption, err = parseStateEncryptionSpec(encryption)
+		if err != nil {
+			return fmt.Errorf("error in project config: state_encryption: %v", err.Error())
+		}
+	}
+
+	if _, exists := p.variablesOverrides[projectOverridesKey]; exists {
+		variables, ok := prjConfParsed["variables"].(map[string]interface{})
+		if !ok {
+			if prjConfParsed["variables"] != nil {
+				return fmt.Errorf("error in project config: 'variables' should be a map")
+			}
+			variables = map[string]interface{}{}
+			prjConfParsed["variables"] = variables
+		}
+		p.overrideVariables(projectOverridesKey, variables)
+	}
 	p.configData["project"] = prjConfParsed
 	return nil
 }
@@ -73,6 +103,29 @@ func (p *Project) readManifests() (err error) {
 			return fmt.Errorf("reading configs %v: %v", file, err)
 		}
 	}
+	if p.configDataFile != nil {
+		patterns, err := readIncludePatterns(p.configDataFile)
+		if err != nil {
+			return err
+		}
+		included, err := includedManifests(patterns)
+		if err != nil {
+			return err
+		}
+		for _, file := range included {
+			if _, exists := objFiles[file]; exists || filepath.Dir(file) == filepath.Clean(config.Global.WorkingDir) {
+				continue
+			}
+			objFiles[file], err = os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("reading configs %v: %v", file, err)
+			}
+		}
+	}
+	err = p.readEnvManifests(objFiles)
+	if err != nil {
+		return err
+	}
 	p.objectsFiles = objFiles
 	return nil
 }
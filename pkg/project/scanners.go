@@ -26,7 +26,11 @@ func OutputsScanner(data reflect.Value, unit Unit) (reflect.Value, error) {
 			modKey := fmt.Sprintf("%s.%s", link.TargetStackName, link.TargetUnitName)
 			depUnit, exists := unit.Project().Units[modKey]
 			if !exists {
-				return reflect.ValueOf(nil), fmt.Errorf("depend unit does not exists. Src: '%s.%s', depend: '%s'", unit.Stack().Name, unit.Name(), modKey)
+				err := unit.Project().UnresolvedLink(fmt.Errorf("depend unit does not exists. Src: '%s.%s', depend: '%s'", unit.Stack().Name, unit.Name(), modKey))
+				if err != nil {
+					return reflect.ValueOf(nil), err
+				}
+				continue
 			}
 			// Add unit ptr to unit link.
 			if link.Unit == nil {
@@ -9,6 +9,7 @@ import (
 	"github.com/apex/log"
 	"github.com/olekukonko/tablewriter"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/shalb/cluster.dev/pkg/utils"
 )
 
@@ -21,7 +22,8 @@ type Secret struct {
 }
 
 func (p *Project) readSecrets() error {
-	for filename, data := range p.objectsFiles {
+	for _, filename := range p.sortedObjectsFiles() {
+		data := p.objectsFiles[filename]
 		templatedData, isWarn, tmplErr := p.TemplateTry(data, filename)
 		if tmplErr != nil && !isWarn {
 			log.Debug(tmplErr.Error())
@@ -40,9 +42,13 @@ func (p *Project) readSecrets() error {
 		if err != nil {
 			return fmt.Errorf("searching for secrets in %v: %v", filename, err.Error())
 		}
-		if _, exists := p.secrets[name]; exists {
-			return fmt.Errorf("searching for secrets in the project dir: duplicated secret name '%v'", name)
+		if existing, exists := p.secrets[name]; exists {
+			first, _ := filepath.Rel(config.Global.WorkingDir, existing.Filename)
+			second, _ := filepath.Rel(config.Global.WorkingDir, filename)
+			return fmt.Errorf("searching for secrets in the project dir: duplicated secret name '%v' in '%v' and '%v'", name, first, second)
 		}
+		// Secret values are masked in the cdev output.
+		sensitive.AddData(d)
 		// For use in templating.
 		p.secrets[name] = Secret{Filename: filename, DriverKey: secretDriver.Key(), Data: d}
 		if _, exists := p.configData["secret"]; !exists {
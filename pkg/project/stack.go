@@ -23,6 +23,10 @@ type Stack struct {
 	Templates   []stackTemplate
 	Variables   map[string]interface{}
 	ConfigData  map[string]interface{}
+	// VariablesSpec declarations of the template variables.
+	VariablesSpec map[string]*variableSpec
+	// unresolvedKeys the unresolved template keys of the stack templates, reported by validation.
+	unresolvedKeys []string
 }
 
 func (p *Project) readStacks() error {
@@ -37,6 +41,9 @@ func (p *Project) readStacks() error {
 		}
 		log.Warnf("'Infrastructure' key is deprecated and will be removed in future releases. Use 'Stack' instead")
 	}
+	if err := p.checkVariablesOverrides(stacks); err != nil {
+		return err
+	}
 	for _, stack := range stacks {
 		err := p.readStackObj(stack)
 		if err != nil {
@@ -88,6 +95,7 @@ func (p *Project) readStackObj(stackSpec ObjectData) error {
 	if !ok {
 		return fmt.Errorf("stack object must contain field 'variables'")
 	}
+	p.overrideVariables(name, stack.Variables)
 	err := stack.ReadTemplate(tmplSource)
 	if err != nil {
 		return err
@@ -155,18 +163,39 @@ func (s *Stack) ReadTemplate(src string) (err error) {
 	if err != nil {
 		return err
 	}
-	s.Templates = []stackTemplate{}
-	for _, fn := range templatesFilesList {
-		tmplData, err := os.ReadFile(fn)
+	templatesData := make([][]byte, len(templatesFilesList))
+	s.VariablesSpec = map[string]*variableSpec{}
+	for i, fn := range templatesFilesList {
+		templatesData[i], err = os.ReadFile(fn)
 		if err != nil {
 			return err
 		}
+		spec, err := readVariablesSpec(templatesData[i], fn)
+		if err != nil {
+			return err
+		}
+		for name, v := range spec {
+			if _, exists := s.VariablesSpec[name]; exists {
+				return fmt.Errorf("reading templates: variable '%v' is declared twice, file: '%v'", name, fn)
+			}
+			s.VariablesSpec[name] = v
+		}
+	}
+	// Defaults should be set before the templates are rendered.
+	err = s.checkVariables()
+	if err != nil {
+		return err
+	}
+	s.Templates = []stackTemplate{}
+	for i, fn := range templatesFilesList {
+		tmplData := templatesData[i]
 		var errIsWarn bool
 		template, errIsWarn, err := s.TemplateTry(tmplData, fn)
 		if err != nil {
 			if !errIsWarn {
 				return err
 			}
+			s.unresolvedKeys = append(s.unresolvedKeys, fmt.Sprintf("%v: unresolved template key: %v", fn, err.Error()))
 		}
 		stackTemplate, err := NewStackTemplate(template)
 		if err != nil {
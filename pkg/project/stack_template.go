@@ -18,6 +18,7 @@ type stackTemplate struct {
 	Units            []map[string]interface{} `yaml:"units"`
 	Modules          []map[string]interface{} `yaml:"modules,omitempty"`
 	ReqClientVersion string                   `yaml:"cliVersion"`
+	Variables        map[string]*variableSpec `yaml:"variables,omitempty"`
 }
 
 func NewStackTemplate(data []byte) (*stackTemplate, error) {
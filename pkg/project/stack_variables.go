@@ -0,0 +1,223 @@
+package project
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shalb/cluster.dev/pkg/sensitive"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// variableSpec declaration of the stack template input variable.
+type variableSpec struct {
+	Type        string        `yaml:"type,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Default     interface{}   `yaml:"default,omitempty"`
+	Required    bool          `yaml:"required,omitempty"`
+	Enum        []interface{} `yaml:"enum,omitempty"`
+	Regex       string        `yaml:"regex,omitempty"`
+	Sensitive   bool          `yaml:"sensitive,omitempty"`
+}
+
+// variableTypes checks the value type. Empty type means any value.
+var variableTypes = map[string]func(interface{}) bool{
+	"":    func(interface{}) bool { return true },
+	"any": func(interface{}) bool { return true },
+	"string": func(v interface{}) bool {
+		_, ok := v.(string)
+		return ok
+	},
+	"number": func(v interface{}) bool {
+		switch v.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	},
+	"bool": func(v interface{}) bool {
+		_, ok := v.(bool)
+		return ok
+	},
+	"list": func(v interface{}) bool {
+		_, ok := v.([]interface{})
+		return ok
+	},
+	"map": func(v interface{}) bool {
+		_, ok := v.(map[string]interface{})
+		return ok
+	},
+}
+
+// variablesKeyRe matches the top level 'variables' key line, the value can follow the key on the same line.
+var variablesKeyRe = regexp.MustCompile(`^variables\s*:(\s|$)`)
+
+// documentEndRe matches the yaml document separator line.
+var documentEndRe = regexp.MustCompile(`^(---|\.\.\.)(\s|$)`)
+
+// templateBlockRe matches the template actions, which open and close the conditional blocks.
+var templateBlockRe = regexp.MustCompile(`{{-?\s*(if|range|with|define|block|end)\b`)
+
+// readVariablesSpec reads the variables declarations from the template file. The declarations are needed
+// before the template is rendered, so they are taken from the top level 'variables' key of the first yaml
+// document of the raw file. The block can't be inside the template actions like 'if' and can't contain
+// template expressions. The key line with all following indented lines is parsed, so both block and flow
+// styles are supported.
+func readVariablesSpec(data []byte, fileName string) (map[string]*variableSpec, error) {
+	block := []string{}
+	inBlock := false
+	openActions := 0
+	hasContent := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if !inBlock {
+			if documentEndRe.MatchString(line) {
+				if hasContent {
+					break
+				}
+				continue
+			}
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				hasContent = true
+			}
+			inBlock = variablesKeyRe.MatchString(line)
+			if inBlock {
+				if openActions > 0 {
+					return nil, fmt.Errorf("read variables declarations from '%v': the 'variables' block can't be inside the template action", fileName)
+				}
+				block = append(block, line)
+				continue
+			}
+			for _, action := range templateBlockRe.FindAllStringSubmatch(line, -1) {
+				if action[1] == "end" {
+					openActions--
+				} else {
+					openActions++
+				}
+			}
+			continue
+		}
+		// The end of the flow style value can be not indented.
+		if line != "" && !strings.ContainsAny(line[:1], " \t#}]") {
+			break
+		}
+		block = append(block, line)
+	}
+	if !inBlock {
+		return nil, nil
+	}
+	if strings.Contains(strings.Join(block, "\n"), "{{") {
+		return nil, fmt.Errorf("read variables declarations from '%v': the 'variables' block can't contain template expressions", fileName)
+	}
+	blockData := []byte(strings.Join(block, "\n"))
+	spec := struct {
+		Variables map[string]*variableSpec `yaml:"variables"`
+	}{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(blockData)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("read variables declarations from '%v': %w", fileName, utils.ResolveYamlError(blockData, err))
+	}
+	for name, v := range spec.Variables {
+		if v == nil {
+			spec.Variables[name] = &variableSpec{}
+			continue
+		}
+		if _, exists := variableTypes[v.Type]; !exists {
+			return nil, fmt.Errorf("read variables declarations from '%v': variable '%v': unknown type '%v'", fileName, name, v.Type)
+		}
+		if v.Regex != "" {
+			if _, err := regexp.Compile(v.Regex); err != nil {
+				return nil, fmt.Errorf("read variables declarations from '%v': variable '%v': %w", fileName, name, err)
+			}
+		}
+	}
+	return spec.Variables, nil
+}
+
+// checkValue returns the violations of the variable declaration.
+func (v *variableSpec) checkValue(value interface{}) []string {
+	if !variableTypes[v.Type](value) {
+		return []string{fmt.Sprintf("expected %v, got %v", v.Type, yamlTypeName(value))}
+	}
+	errs := []string{}
+	if len(v.Enum) > 0 {
+		allowed := false
+		for _, e := range v.Enum {
+			if reflect.DeepEqual(e, value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			errs = append(errs, fmt.Sprintf("value '%v' is not one of %v", value, v.Enum))
+		}
+	}
+	if v.Regex != "" {
+		str, ok := value.(string)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("regex can be checked for strings only, got %v", yamlTypeName(value)))
+		} else if !regexp.MustCompile(v.Regex).MatchString(str) {
+			errs = append(errs, fmt.Sprintf("value '%v' does not match regex '%v'", str, v.Regex))
+		}
+	}
+	return errs
+}
+
+func yamlTypeName(value interface{}) string {
+	for _, t := range []string{"string", "number", "bool", "list", "map"} {
+		if variableTypes[t](value) {
+			return t
+		}
+	}
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkVariables validates the stack variables against the template declarations and sets the default
+// values of the unset variables. All violations are reported at once. Templates without declarations
+// are not checked.
+func (s *Stack) checkVariables() error {
+	if len(s.VariablesSpec) == 0 {
+		return nil
+	}
+	errs := []string{}
+	for name, spec := range s.VariablesSpec {
+		value, exists := s.Variables[name]
+		if !exists || value == nil {
+			if spec.Required {
+				errs = append(errs, fmt.Sprintf("variable '%v': required, but not set", name))
+				continue
+			}
+			if spec.Default == nil {
+				continue
+			}
+			for _, e := range spec.checkValue(spec.Default) {
+				errs = append(errs, fmt.Sprintf("variable '%v': default: %v", name, e))
+			}
+			s.Variables[name] = spec.Default
+			value = spec.Default
+		} else {
+			for _, e := range spec.checkValue(value) {
+				errs = append(errs, fmt.Sprintf("variable '%v': %v", name, e))
+			}
+		}
+		if spec.Sensitive {
+			sensitive.AddData(value)
+		}
+	}
+	for name := range s.Variables {
+		if _, declared := s.VariablesSpec[name]; !declared {
+			errs = append(errs, fmt.Sprintf("variable '%v': not declared in the template", name))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("stack '%v': invalid variables:\n  %v", s.Name, strings.Join(errs, "\n  "))
+	}
+	return nil
+}
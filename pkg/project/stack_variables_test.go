@@ -0,0 +1,117 @@
+package project
+
+import (
+	"strings"
+	"testing"
+)
+
+const testVariablesTemplate = `name: test
+kind: StackTemplate
+variables:
+  region:
+    type: string
+    required: true
+    regex: "^[a-z]+-[a-z]+-[0-9]$"
+  size:
+    type: string
+    enum: [small, large]
+    default: small
+  replicas:
+    type: number
+    default: 2
+  # Comments are allowed in the declarations.
+  tags:
+    type: map
+units:
+  - name: {{ .variables.region }}
+    type: shell
+`
+
+func TestReadVariablesSpec(t *testing.T) {
+	spec, err := readVariablesSpec([]byte(testVariablesTemplate), "template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec) != 4 {
+		t.Fatalf("expected 4 declarations, got %v", len(spec))
+	}
+	if !spec["region"].Required || spec["size"].Default != "small" || spec["tags"].Type != "map" {
+		t.Errorf("unexpected declarations: %+v", spec)
+	}
+	for _, data := range []string{
+		"variables: {region: {type: string, required: true}, size: {default: small}}\nunits: []\n",
+		"variables: {\n  region: {type: string, required: true},\n  size: {default: small}\n}\nunits: []\n",
+		"variables:  # declarations\n  region:\n    type: string\n    required: true\n  size:\n    default: small\nunits: []\n",
+	} {
+		spec, err := readVariablesSpec([]byte(data), "template.yaml")
+		if err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		if len(spec) != 2 || !spec["region"].Required || spec["size"].Default != "small" {
+			t.Errorf("%q: unexpected declarations: %+v", data, spec)
+		}
+	}
+	if _, err := readVariablesSpec([]byte("variables: {{ .vars }}\n"), "template.yaml"); err == nil {
+		t.Error("expected error for the template expression")
+	}
+	if _, err := readVariablesSpec([]byte("variables:\n  a:\n    default: \"{{ .vars.a }}\"\n"), "template.yaml"); err == nil {
+		t.Error("expected error for the quoted template expression")
+	}
+	if _, err := readVariablesSpec([]byte("{{ if .enabled }}\nvariables:\n  a: {}\n{{ end }}\n"), "template.yaml"); err == nil {
+		t.Error("expected error for the block inside the template action")
+	}
+	for _, data := range []string{
+		"{{ if .enabled }}\nname: a\n{{ end }}\nvariables:\n  a: {}\n",
+		"---\n# declarations\nvariables:\n  a: {}\n---\nvariables:\n  b: {}\n",
+	} {
+		spec, err := readVariablesSpec([]byte(data), "template.yaml")
+		if err != nil || len(spec) != 1 || spec["a"] == nil {
+			t.Errorf("%q: expected the declarations of the first document, got %+v, error %v", data, spec, err)
+		}
+	}
+	if spec, err := readVariablesSpec([]byte("name: a\n---\nvariables:\n  a: {}\n"), "template.yaml"); err != nil || spec != nil {
+		t.Errorf("the declarations of the second document should be ignored, got %+v, error %v", spec, err)
+	}
+	if _, err := readVariablesSpec([]byte("variables: [region]\n"), "template.yaml"); err == nil {
+		t.Error("expected error for the list of variables")
+	}
+	if _, err := readVariablesSpec([]byte("variables:\n  a:\n    type: text\n"), "template.yaml"); err == nil {
+		t.Error("expected unknown type error")
+	}
+	if _, err := readVariablesSpec([]byte("variables:\n  a:\n    typo: string\n"), "template.yaml"); err == nil {
+		t.Error("expected unknown field error")
+	}
+}
+
+func TestCheckVariables(t *testing.T) {
+	spec, err := readVariablesSpec([]byte(testVariablesTemplate), "template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Stack{Name: "test", VariablesSpec: spec, Variables: map[string]interface{}{"region": "eu-central-1", "replicas": 3}}
+	if err := s.checkVariables(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Variables["size"] != "small" || s.Variables["replicas"] != 3 {
+		t.Errorf("unexpected variables after defaults: %v", s.Variables)
+	}
+	if _, exists := s.Variables["tags"]; exists {
+		t.Error("variable without default was set")
+	}
+
+	s = Stack{Name: "test", VariablesSpec: spec, Variables: map[string]interface{}{"size": "medium", "replicas": "3", "tagz": "x"}}
+	err = s.checkVariables()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, expected := range []string{
+		"variable 'region': required, but not set",
+		"variable 'size': value 'medium' is not one of [small large]",
+		"variable 'replicas': expected number, got string",
+		"variable 'tagz': not declared in the template",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error does not contain %q:\n%v", expected, err)
+		}
+	}
+}
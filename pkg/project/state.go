@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/shalb/cluster.dev/pkg/colors"
 	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/shalb/cluster.dev/pkg/utils"
 )
 
@@ -29,6 +32,8 @@ func (sp *StateProject) UpdateUnit(unit Unit) {
 }
 
 func (sp *StateProject) DeleteUnit(mod Unit) {
+	sp.StateMutex.Lock()
+	defer sp.StateMutex.Unlock()
 	delete(sp.Units, mod.Key())
 }
 
@@ -44,9 +49,17 @@ type StateProject struct {
 	Project
 	LoaderProjectPtr *Project
 	ChangedUnits     map[string]Unit
+	saver            *stateSaver
 }
 
+// SaveState writes the state to the backend and saves it to the state history. Should be called at the end of the run.
 func (p *Project) SaveState() error {
+	return p.saveState(true)
+}
+
+// saveState writes the state to the backend. The background saves during the run are done without the state
+// history, so one run creates one history version.
+func (p *Project) saveState(withHistory bool) error {
 	p.StateMutex.Lock()
 	defer p.StateMutex.Unlock()
 	st := stateData{
@@ -57,9 +70,15 @@ func (p *Project) SaveState() error {
 	}
 	// log.Errorf("units links: %+v\n Project: %+v", st.UnitLinks, p.UnitLinks)
 	for key, unit := range p.Units {
-		log.Warnf("SaveState %v", key)
+		log.Debugf("SaveState %v", key)
 		st.Units[key] = unit.GetState()
 	}
+	return p.writeStateData(&st, withHistory)
+}
+
+// writeStateData encodes and writes the state data to the backend, and optionally saves it to the state history.
+func (p *Project) writeStateData(st *stateData, withHistory bool) error {
+	st.SchemaVersion = stateSchemaVersion()
 	// Remove all unit links, that not have a target unit.
 	st.ClearULinks()
 	buffer := &bytes.Buffer{}
@@ -70,52 +89,55 @@ func (p *Project) SaveState() error {
 	if err != nil {
 		return fmt.Errorf("saving project state: %v", err.Error())
 	}
-	if p.StateBackendName == "" {
-		return fmt.Errorf("internal error: empty project backend")
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("saving project state: %w", err)
 	}
-	sBk, ok := p.Backends[p.StateBackendName]
-	if !ok {
-		return fmt.Errorf("lock state: state backend '%v' does not found", p.StateBackendName)
+	encrypted, err := p.encryptState(buffer.String())
+	if err != nil {
+		return fmt.Errorf("saving project state: %w", err)
 	}
-	return sBk.WriteState(buffer.String())
-}
-
-type stateData struct {
-	CdevVersion string                 `json:"version"`
-	ProjectUUID string                 `json:"project_uuid,omitempty"`
-	UnitLinks   *UnitLinksT            `json:"unit_links"`
-	Units       map[string]interface{} `json:"units"`
-}
-
-func (p *Project) LockState() error {
-	if p.StateBackendName == "" {
-		return fmt.Errorf("internal error: empty project backend")
+	if p.stateMigrated {
+		log.Infof("Migrating the state to schema version %v", st.SchemaVersion)
+		err = p.BackupState()
+		if err != nil {
+			return fmt.Errorf("saving project state: backup state before migration: %w", err)
+		}
+		p.stateMigrated = false
 	}
-
-	sBk, ok := p.Backends[p.StateBackendName]
-	if !ok {
-		return fmt.Errorf("lock state: state backend '%v' does not found", p.StateBackendName)
+	err = sBk.WriteState(encrypted)
+	if err != nil {
+		return err
 	}
-	return sBk.LockState()
-
+	if !withHistory {
+		return nil
+	}
+	err = p.saveStateVersion(buffer.String())
+	if err != nil {
+		log.Warnf("Saving the state version to the history: %v", err.Error())
+	}
+	return nil
 }
 
-func (p *Project) UnLockState() error {
-	if p.StateBackendName == "" {
-		return fmt.Errorf("internal error: empty project backend")
-	}
+type stateData struct {
+	CdevVersion   string                 `json:"version"`
+	SchemaVersion int                    `json:"schema_version"`
+	ProjectUUID   string                 `json:"project_uuid,omitempty"`
+	UnitLinks     *UnitLinksT            `json:"unit_links"`
+	Units         map[string]interface{} `json:"units"`
+}
 
-	sBk, ok := p.Backends[p.StateBackendName]
-	if !ok {
-		return fmt.Errorf("lock state: state backend '%v' does not found", p.StateBackendName)
+// GetState returns the remote state data, decrypted if the state is encrypted.
+func (p *Project) GetState() ([]byte, error) {
+	data, err := p.readRawState()
+	if err != nil {
+		return nil, err
 	}
-	return sBk.UnlockState()
+	return p.decryptState(data)
 }
 
-func (p *Project) GetState() ([]byte, error) {
-	var stateStr string
-	var err error
-	var loadedStateFile []byte
+// readRawState returns the remote state data as it is stored in the backend.
+func (p *Project) readRawState() ([]byte, error) {
 	if p.StateBackendName == "" {
 		return nil, fmt.Errorf("internal error: empty project backend")
 	}
@@ -123,13 +145,11 @@ func (p *Project) GetState() ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf("get remote state data: state backend '%v' does not found", p.StateBackendName)
 	}
-	stateStr, err = sBk.ReadState()
+	stateStr, err := sBk.ReadState()
 	if err != nil {
 		return nil, fmt.Errorf("get remote state data: %w", err)
 	}
-	loadedStateFile = []byte(stateStr)
-
-	return loadedStateFile, nil
+	return []byte(stateStr), nil
 }
 
 func (p *Project) PullState() error {
@@ -139,18 +159,91 @@ func (p *Project) PullState() error {
 	}
 	bkFileName := filepath.Join(config.Global.WorkingDir, "cdev.state")
 	log.Infof("Pulling state file: %v", bkFileName)
-	return os.WriteFile(bkFileName, loadedStateFile, 0660)
+	if p.stateEncryption != nil {
+		log.Warnf("The pulled state file is not encrypted. Delete it after use")
+	}
+	if err = os.WriteFile(bkFileName, loadedStateFile, 0600); err != nil {
+		return fmt.Errorf("pull state: %w", err)
+	}
+	// The file could be pulled before with wider permissions, WriteFile does not change the mode of existing files.
+	return os.Chmod(bkFileName, 0600)
 }
 
+// PushState validates the state file and uploads it to the state backend. The current remote state is backed up before.
+func (p *Project) PushState(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	data, err = p.decryptState(data)
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	newState, _, err := parseState(data)
+	if err != nil {
+		return fmt.Errorf("push state: parse '%v': %w", fileName, err)
+	}
+	currentState, err := p.readStateData()
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	if currentState.ProjectUUID != "" && newState.ProjectUUID != currentState.ProjectUUID {
+		return fmt.Errorf("push state: project UUID mismatch: file '%v', remote state '%v'. Make sure the file is the state of this project", newState.ProjectUUID, currentState.ProjectUUID)
+	}
+	errs := []string{}
+	for key, unitState := range newState.Units {
+		unitMap, ok := unitState.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unit '%v': unexpected state format", key))
+			continue
+		}
+		unitType, _ := unitMap["type"].(string)
+		if _, exists := UnitFactoriesMap[unitType]; !exists && unitType != "terraform" {
+			errs = append(errs, fmt.Sprintf("unit '%v': unknown unit type '%v'", key, unitType))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("push state: invalid state file '%v':\n  %v", fileName, strings.Join(errs, "\n  "))
+	}
+	_, err = p.newStateProject(newState)
+	if err != nil {
+		return fmt.Errorf("push state: invalid state file '%v': %w", fileName, err)
+	}
+	err = p.BackupState()
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	log.Infof("Pushing state file: %v", fileName)
+	encrypted, err := p.encryptState(string(data))
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	err = sBk.WriteState(encrypted)
+	if err != nil {
+		return fmt.Errorf("push state: %w", err)
+	}
+	err = p.saveStateVersion(string(data))
+	if err != nil {
+		log.Warnf("Saving the state version to the history: %v", err.Error())
+	}
+	return nil
+}
+
+// BackupState saves the remote state to the local file. Encrypted states are saved encrypted.
 func (p *Project) BackupState() error {
-	loadedStateFile, err := p.GetState()
+	loadedStateFile, err := p.readRawState()
 	if err != nil {
 		return fmt.Errorf("backup state: %w", err)
 	}
 	const layout = "20060102150405"
 	bkFileName := filepath.Join(config.Global.WorkingDir, fmt.Sprintf("cdev.state.backup.%v", time.Now().Format(layout)))
 	log.Infof("Backuping state file: %v", bkFileName)
-	return os.WriteFile(bkFileName, loadedStateFile, 0660)
+	return os.WriteFile(bkFileName, loadedStateFile, 0600)
 }
 
 func createProjectUUID() string {
@@ -164,20 +257,25 @@ func (p *Project) NewEmptyState() *StateProject {
 	}
 	statePrj := StateProject{
 		Project: Project{
-			name:             p.Name(),
-			secrets:          p.secrets,
-			configData:       p.configData,
-			configDataFile:   p.configDataFile,
-			objects:          p.objects,
-			Units:            make(map[string]Unit),
-			UnitLinks:        stateD.UnitLinks,
-			Stacks:           make(map[string]*Stack),
-			Backends:         p.Backends,
-			CodeCacheDir:     config.Global.StateCacheDir,
-			StateBackendName: p.StateBackendName,
-			StateMutex:       sync.Mutex{},
-			InitLock:         sync.Mutex{},
-			UUID:             p.UUID,
+			name:              p.Name(),
+			SessionId:         p.SessionId,
+			secrets:           p.secrets,
+			configData:        p.configData,
+			configDataFile:    p.configDataFile,
+			objects:           p.objects,
+			Units:             make(map[string]Unit),
+			UnitLinks:         stateD.UnitLinks,
+			Stacks:            make(map[string]*Stack),
+			Backends:          p.Backends,
+			CodeCacheDir:      config.Global.StateCacheDir,
+			StateBackendName:  p.StateBackendName,
+			StateMutex:        sync.Mutex{},
+			InitLock:          sync.Mutex{},
+			UUID:              p.UUID,
+			stateHistoryLimit: p.stateHistoryLimit,
+			stateMigrated:     p.stateMigrated,
+			stateEncryption:   p.stateEncryption,
+			env:               p.env,
 		},
 		LoaderProjectPtr: p,
 		ChangedUnits:     make(map[string]Unit),
@@ -197,22 +295,15 @@ func (p *Project) LoadState() (*StateProject, error) {
 		return nil, fmt.Errorf("load state: remove state cache dir: %w", err)
 	}
 
-	stateD := stateData{
-		UnitLinks: &UnitLinksT{},
-	}
-
 	loadedStateFile, err := p.GetState()
 	if err != nil {
 		return nil, err
 	}
-	if len(loadedStateFile) > 0 {
-		// log.Warnf("LoadState():\n%s", string(loadedStateFile))
-		err = utils.JSONDecode(loadedStateFile, &stateD)
-		if err != nil {
-			return nil, fmt.Errorf("load state: %w", err)
-		}
+	p.stateHash = utils.Md5(string(loadedStateFile))
+	stateD, err := p.decodeRemoteState(loadedStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("load state: %w", err)
 	}
-	stateD.ClearULinks()
 	p.UUID = stateD.ProjectUUID
 	if p.UUID == "" {
 		p.UUID = createProjectUUID()
@@ -220,6 +311,25 @@ func (p *Project) LoadState() (*StateProject, error) {
 	} else {
 		log.Debugf("Project UUID loaded from state: %v", p.UUID)
 	}
+	statePrj, err := p.newStateProject(stateD)
+	if err != nil {
+		return nil, err
+	}
+	for key, _ := range statePrj.Units {
+		log.Warnf("LoadState %v", key)
+	}
+
+	return statePrj, nil
+}
+
+// newStateProject creates the state project and loads units from the state data.
+func (p *Project) newStateProject(stateD *stateData) (*StateProject, error) {
+	stateD.ClearULinks()
+	for _, link := range stateD.UnitLinks.Map() {
+		if link.Sensitive {
+			sensitive.AddData(link.OutputData)
+		}
+	}
 	statePrj := p.NewEmptyState()
 	statePrj.UnitLinks = stateD.UnitLinks
 	for mName, mState := range stateD.Units {
@@ -233,14 +343,10 @@ func (p *Project) LoadState() (*StateProject, error) {
 		statePrj.Units[mName] = unit
 		unit.UpdateProjectRuntimeData(&statePrj.Project)
 	}
-	err = statePrj.prepareUnits()
+	err := statePrj.prepareUnits()
 	if err != nil {
 		return nil, err
 	}
-	for key, _ := range statePrj.Units {
-		log.Warnf("LoadState %v", key)
-	}
-
 	return statePrj, nil
 }
 
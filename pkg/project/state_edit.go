@@ -0,0 +1,311 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// terraformUnitKinds unit types, which keep the Terraform state in the backend by the stack and unit names.
+var terraformUnitKinds = map[string]bool{
+	"tfmodule":   true,
+	"helm":       true,
+	"kubernetes": true,
+	"printer":    true,
+}
+
+// readStateData reads and parses the raw project state, without loading units.
+func (p *Project) readStateData() (*stateData, error) {
+	data, err := p.GetState()
+	if err != nil {
+		return nil, err
+	}
+	st, err := p.decodeRemoteState(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse state: %w", err)
+	}
+	return st, nil
+}
+
+// splitUnitKey splits the unit key 'stack.unit' to the stack and unit names.
+func splitUnitKey(key string) (stackName, unitName string, err error) {
+	spl := strings.Split(key, ".")
+	if len(spl) != 2 || spl[0] == "" || spl[1] == "" {
+		return "", "", fmt.Errorf("bad unit key '%v', expected format 'stack_name.unit_name'", key)
+	}
+	return spl[0], spl[1], nil
+}
+
+// rawUnitDeps returns the depends_on list of the unit state as is.
+func rawUnitDeps(unitState interface{}) []string {
+	unitMap, ok := unitState.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	switch deps := unitMap["depends_on"].(type) {
+	case string:
+		res = append(res, deps)
+	case []interface{}:
+		for _, dep := range deps {
+			if depStr, ok := dep.(string); ok {
+				res = append(res, depStr)
+			}
+		}
+	}
+	return res
+}
+
+// resolveDep converts the depends_on entry of the unit in the stack to the unit key ('this.unit' -> 'stack.unit').
+func resolveDep(dep, stackName string) string {
+	if strings.HasPrefix(dep, "this.") {
+		return stackName + strings.TrimPrefix(dep, "this")
+	}
+	return dep
+}
+
+// unitDependencies returns the keys of the units, which the unit in the state depends on (depends_on, outputs and remote states).
+func (st *stateData) unitDependencies(key string) map[string]bool {
+	res := map[string]bool{}
+	unitState, exists := st.Units[key]
+	if !exists {
+		return res
+	}
+	stackName, _, _ := splitUnitKey(key)
+	for _, dep := range rawUnitDeps(unitState) {
+		res[resolveDep(dep, stackName)] = true
+	}
+	unitStr, err := utils.JSONEncodeString(unitState)
+	if err != nil {
+		return res
+	}
+	for marker, link := range st.UnitLinks.Map() {
+		if strings.Contains(unitStr, marker) {
+			res[link.UnitKey()] = true
+		}
+	}
+	delete(res, key)
+	return res
+}
+
+// replaceStrings replaces all occurrences of the old strings with the new ones in all string values of the data.
+func replaceStrings(data interface{}, replacer *strings.Replacer) interface{} {
+	switch val := data.(type) {
+	case string:
+		return replacer.Replace(val)
+	case map[string]interface{}:
+		for k, v := range val {
+			val[k] = replaceStrings(v, replacer)
+		}
+	case []interface{}:
+		for i, v := range val {
+			val[i] = replaceStrings(v, replacer)
+		}
+	}
+	return data
+}
+
+// ShowStateUnit prints the unit state and the unit outputs, used by other units.
+func (p *Project) ShowStateUnit(key string) error {
+	st, err := p.readStateData()
+	if err != nil {
+		return fmt.Errorf("state show: %w", err)
+	}
+	unitState, exists := st.Units[key]
+	if !exists {
+		return fmt.Errorf("state show: unit '%v' not found in the state", key)
+	}
+	links := []*ULinkT{}
+	for _, link := range st.UnitLinks.Map() {
+		if link.UnitKey() == key && link.LinkType != CustomLinkType {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].LinkPath() < links[j].LinkPath()
+	})
+	for _, link := range st.UnitLinks.Map() {
+		if link.Sensitive {
+			sensitive.AddData(link.OutputData)
+		}
+	}
+	res, err := utils.JSONEncodeString(map[string]interface{}{
+		"unit":  unitState,
+		"links": links,
+	})
+	if err != nil {
+		return fmt.Errorf("state show: %w", err)
+	}
+	fmt.Println(sensitive.MaskString(res))
+	return nil
+}
+
+// RemoveStateUnits removes the units and their links from the state. The unit resources are not destroyed.
+func (p *Project) RemoveStateUnits(keys []string) error {
+	st, err := p.readStateData()
+	if err != nil {
+		return fmt.Errorf("state rm: %w", err)
+	}
+	err = st.removeUnits(keys)
+	if err != nil {
+		return fmt.Errorf("state rm: %w", err)
+	}
+	// Links to the removed units are deleted on save.
+	err = p.writeStateData(st, true)
+	if err != nil {
+		return fmt.Errorf("state rm: %w", err)
+	}
+	return nil
+}
+
+// removeUnits removes the units from the state data. Units, which are used by other units, are not removed.
+func (st *stateData) removeUnits(keys []string) error {
+	removed := map[string]bool{}
+	for _, key := range keys {
+		if _, exists := st.Units[key]; !exists {
+			return fmt.Errorf("unit '%v' not found in the state", key)
+		}
+		removed[key] = true
+	}
+	errs := []string{}
+	for key := range st.Units {
+		if removed[key] {
+			continue
+		}
+		for dep := range st.unitDependencies(key) {
+			if removed[dep] {
+				errs = append(errs, fmt.Sprintf("unit '%v' depends on '%v'", key, dep))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("units are used by other units, remove them together:\n  %v", strings.Join(errs, "\n  "))
+	}
+	for key := range removed {
+		log.Infof("Removing unit '%v' from the state", key)
+		delete(st.Units, key)
+	}
+	return nil
+}
+
+// MoveStateUnit renames the unit in the state: moves the unit state to the new key and updates the links
+// and the dependencies of other units.
+func (p *Project) MoveStateUnit(oldKey, newKey string) error {
+	st, err := p.readStateData()
+	if err != nil {
+		return fmt.Errorf("state mv: %w", err)
+	}
+	err = st.moveUnit(oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("state mv: %w", err)
+	}
+	err = p.writeStateData(st, true)
+	if err != nil {
+		return fmt.Errorf("state mv: %w", err)
+	}
+	return nil
+}
+
+// moveUnit moves the unit in the state data to the new key.
+func (st *stateData) moveUnit(oldKey, newKey string) error {
+	oldStack, _, err := splitUnitKey(oldKey)
+	if err != nil {
+		return err
+	}
+	newStack, newUnit, err := splitUnitKey(newKey)
+	if err != nil {
+		return err
+	}
+	unitState, exists := st.Units[oldKey]
+	if !exists {
+		return fmt.Errorf("unit '%v' not found in the state", oldKey)
+	}
+	if _, exists := st.Units[newKey]; exists {
+		return fmt.Errorf("unit '%v' already exists in the state", newKey)
+	}
+	unitMap, ok := unitState.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unit '%v' has unexpected state format", oldKey)
+	}
+	// Move the unit itself. Relative dependencies of the unit are kept pointing to the old stack.
+	unitMap["name"] = newUnit
+	if oldStack != newStack {
+		unitMap["depends_on"] = rewriteDeps(unitMap["depends_on"], func(dep string) string {
+			if strings.HasPrefix(dep, "this.") {
+				return resolveDep(dep, oldStack)
+			}
+			return dep
+		})
+	}
+	delete(st.Units, oldKey)
+	st.Units[newKey] = unitMap
+
+	// Re-key the links to the moved unit. Markers are generated from the link path, so they change too.
+	markers := []string{}
+	for marker, link := range st.UnitLinks.Map() {
+		if link.UnitKey() != oldKey {
+			continue
+		}
+		newLink := *link
+		newLink.TargetStackName = newStack
+		newLink.TargetUnitName = newUnit
+		newMarker, err := CreateMarker(newLink)
+		if err != nil {
+			return err
+		}
+		st.UnitLinks.Delete(marker)
+		st.UnitLinks.Insert(newMarker, &newLink)
+		markers = append(markers, marker, newMarker)
+	}
+
+	// Update the units, which use the moved unit.
+	replacer := strings.NewReplacer(markers...)
+	for key, uState := range st.Units {
+		stackName, _, _ := splitUnitKey(key)
+		uMap, ok := uState.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(markers) > 0 {
+			replaceStrings(uMap, replacer)
+		}
+		uMap["depends_on"] = rewriteDeps(uMap["depends_on"], func(dep string) string {
+			if resolveDep(dep, stackName) != oldKey {
+				return dep
+			}
+			if stackName == newStack {
+				return "this." + newUnit
+			}
+			return newKey
+		})
+		if uMap["depends_on"] == nil {
+			delete(uMap, "depends_on")
+		}
+	}
+	log.Infof("Moving unit '%v' to '%v' in the state", oldKey, newKey)
+	if unitType, _ := unitMap["type"].(string); terraformUnitKinds[unitType] {
+		log.Warnf("Unit '%v' has type '%v'. Its Terraform state is stored in the backend by the stack and unit names and is not moved. Move it manually before the next apply, otherwise the unit resources will be created again", oldKey, unitType)
+	}
+	return nil
+}
+
+// rewriteDeps applies the function to each entry of the depends_on value (string or list).
+func rewriteDeps(deps interface{}, f func(string) string) interface{} {
+	switch val := deps.(type) {
+	case string:
+		return f(val)
+	case []interface{}:
+		for i, dep := range val {
+			if depStr, ok := dep.(string); ok {
+				val[i] = f(depStr)
+			}
+		}
+		return val
+	}
+	return deps
+}
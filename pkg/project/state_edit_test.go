@@ -0,0 +1,174 @@
+package project
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestStateData creates the state data with the units and output links. Units env values may reference
+// the output links by the link path, which is replaced by the marker.
+func newTestStateData(t *testing.T, units map[string]map[string]interface{}, outputs ...string) (*stateData, map[string]string) {
+	st := &stateData{UnitLinks: &UnitLinksT{}, Units: map[string]interface{}{}}
+	markers := map[string]string{}
+	for _, output := range outputs {
+		spl := strings.Split(output, ".")
+		marker, err := st.UnitLinks.Set(&ULinkT{LinkType: OutputLinkType, TargetStackName: spl[0], TargetUnitName: spl[1], OutputName: spl[2]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		markers[output] = marker
+	}
+	for key, unit := range units {
+		if env, ok := unit["env"].(map[string]interface{}); ok {
+			for name, val := range env {
+				if marker, exists := markers[val.(string)]; exists {
+					env[name] = marker
+				}
+			}
+		}
+		st.Units[key] = unit
+	}
+	return st, markers
+}
+
+func TestStateMoveUnit(t *testing.T) {
+	cases := []struct {
+		name         string
+		oldKey       string
+		newKey       string
+		units        map[string]map[string]interface{}
+		outputs      []string
+		expectedDeps map[string]interface{}
+		// expectedEnv the output path, which should be referenced by the unit env after the move.
+		expectedEnv map[string]string
+	}{
+		{
+			name:   "same stack",
+			oldKey: "infra.vpc",
+			newKey: "infra.network",
+			units: map[string]map[string]interface{}{
+				"infra.vpc": {"name": "vpc", "depends_on": "this.base"},
+				"infra.eks": {"name": "eks", "depends_on": []interface{}{"this.vpc", "this.base"}, "env": map[string]interface{}{"VPC": "infra.vpc.id"}},
+				"apps.app":  {"name": "app", "depends_on": "infra.vpc"},
+			},
+			outputs: []string{"infra.vpc.id", "infra.base.id"},
+			expectedDeps: map[string]interface{}{
+				"infra.network": "this.base",
+				"infra.eks":     []interface{}{"this.network", "this.base"},
+				"apps.app":      "infra.network",
+			},
+			expectedEnv: map[string]string{"infra.eks": "infra.network.id"},
+		},
+		{
+			name:   "cross stack",
+			oldKey: "infra.vpc",
+			newKey: "net.vpc",
+			units: map[string]map[string]interface{}{
+				"infra.vpc": {"name": "vpc", "depends_on": []interface{}{"this.base", "other.x"}},
+				"infra.eks": {"name": "eks", "depends_on": "this.vpc"},
+				"net.dns":   {"name": "dns", "depends_on": "infra.vpc", "env": map[string]interface{}{"VPC": "infra.vpc.id"}},
+			},
+			outputs: []string{"infra.vpc.id"},
+			expectedDeps: map[string]interface{}{
+				"net.vpc":   []interface{}{"infra.base", "other.x"},
+				"infra.eks": "net.vpc",
+				"net.dns":   "this.vpc",
+			},
+			expectedEnv: map[string]string{"net.dns": "net.vpc.id"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			st, oldMarkers := newTestStateData(t, c.units, c.outputs...)
+			if err := st.moveUnit(c.oldKey, c.newKey); err != nil {
+				t.Fatal(err)
+			}
+			if _, exists := st.Units[c.oldKey]; exists {
+				t.Errorf("unit '%v' is still in the state", c.oldKey)
+			}
+			for key, deps := range c.expectedDeps {
+				unit := st.Units[key].(map[string]interface{})
+				if !reflect.DeepEqual(unit["depends_on"], deps) {
+					t.Errorf("unit '%v': expected depends_on %v, got %v", key, deps, unit["depends_on"])
+				}
+			}
+			links := st.UnitLinks.Map()
+			for key, output := range c.expectedEnv {
+				marker := st.Units[key].(map[string]interface{})["env"].(map[string]interface{})["VPC"].(string)
+				link, exists := links[marker]
+				if !exists {
+					t.Fatalf("unit '%v': marker %v is not in the links", key, marker)
+				}
+				if path := link.TargetStackName + "." + link.TargetUnitName + "." + link.OutputName; path != output {
+					t.Errorf("unit '%v': expected link %v, got %v", key, output, path)
+				}
+			}
+			for output, marker := range oldMarkers {
+				if strings.HasPrefix(output, c.oldKey+".") {
+					if _, exists := links[marker]; exists {
+						t.Errorf("old marker of %v is still in the links", output)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStateMoveUnitErrors(t *testing.T) {
+	st, _ := newTestStateData(t, map[string]map[string]interface{}{
+		"infra.a": {"name": "a"},
+		"infra.b": {"name": "b"},
+	})
+	for _, keys := range [][2]string{{"infra.x", "infra.y"}, {"infra.a", "infra.b"}, {"infra.a", "bad"}} {
+		if err := st.moveUnit(keys[0], keys[1]); err == nil {
+			t.Errorf("expected error for move %v -> %v", keys[0], keys[1])
+		}
+	}
+}
+
+func TestStateRemoveUnits(t *testing.T) {
+	units := func() map[string]map[string]interface{} {
+		return map[string]map[string]interface{}{
+			"infra.vpc": {"name": "vpc"},
+			"infra.eks": {"name": "eks", "depends_on": "this.vpc"},
+			"apps.app":  {"name": "app", "env": map[string]interface{}{"HOST": "infra.eks.host"}},
+			"apps.solo": {"name": "solo"},
+		}
+	}
+	cases := []struct {
+		keys    []string
+		refused string
+	}{
+		{keys: []string{"infra.vpc"}, refused: "unit 'infra.eks' depends on 'infra.vpc'"},
+		{keys: []string{"infra.eks"}, refused: "unit 'apps.app' depends on 'infra.eks'"},
+		{keys: []string{"infra.nope"}, refused: "not found"},
+		{keys: []string{"infra.vpc", "infra.eks", "apps.app"}},
+		{keys: []string{"apps.solo"}},
+	}
+	for _, c := range cases {
+		st, _ := newTestStateData(t, units(), "infra.eks.host")
+		err := st.removeUnits(c.keys)
+		if c.refused != "" {
+			if err == nil || !strings.Contains(err.Error(), c.refused) {
+				t.Errorf("rm %v: expected error '%v', got %v", c.keys, c.refused, err)
+			}
+			if len(st.Units) != 4 {
+				t.Errorf("rm %v: units were removed after the error", c.keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("rm %v: %v", c.keys, err)
+			continue
+		}
+		for _, key := range c.keys {
+			if _, exists := st.Units[key]; exists {
+				t.Errorf("rm %v: unit '%v' was not removed", c.keys, key)
+			}
+		}
+		if len(st.Units) != 4-len(c.keys) {
+			t.Errorf("rm %v: unexpected units left: %v", c.keys, st.Units)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package project
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// encryptedStateFormat version of the encrypted state envelope format.
+const encryptedStateFormat = "v1"
+
+// defaultStatePassphraseEnv environment variable with the state passphrase, if 'passphrase_env' is not set.
+const defaultStatePassphraseEnv = "CDEV_STATE_PASSPHRASE"
+
+// stateEncryptionSpec the 'state_encryption' project option.
+type stateEncryptionSpec struct {
+	// Provider the key provider name: passphrase, age or secret.
+	Provider      string `yaml:"provider"`
+	PassphraseEnv string `yaml:"passphrase_env,omitempty"`
+	KeyFile       string `yaml:"key_file,omitempty"`
+	Secret        string `yaml:"secret,omitempty"`
+	SecretKey     string `yaml:"secret_key,omitempty"`
+}
+
+// StateKeyProvider wraps and unwraps the data key of the encrypted state.
+type StateKeyProvider interface {
+	WrapKey(key []byte) ([]byte, error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// stateKeyProviderFactories creates the key providers by the 'state_encryption.provider' option.
+var stateKeyProviderFactories = map[string]func(spec *stateEncryptionSpec, p *Project) (StateKeyProvider, error){
+	"passphrase": newPassphraseKeyProvider,
+	"age":        newAgeKeyProvider,
+	"secret":     newSecretKeyProvider,
+}
+
+// encryptedState the envelope of the encrypted state. The state is encrypted with the random data key (AES-256-GCM),
+// the data key is wrapped by the key provider.
+type encryptedState struct {
+	Format      string `json:"cdev_encrypted_state"`
+	KeyProvider string `json:"key_provider"`
+	WrappedKey  []byte `json:"wrapped_key"`
+	Nonce       []byte `json:"nonce"`
+	Data        []byte `json:"data"`
+}
+
+func parseStateEncryptionSpec(data interface{}) (*stateEncryptionSpec, error) {
+	raw, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	spec := stateEncryptionSpec{}
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&spec)
+	if err != nil {
+		return nil, err
+	}
+	switch spec.Provider {
+	case "passphrase":
+	case "age":
+		if spec.KeyFile == "" {
+			return nil, fmt.Errorf("'key_file' is required for the 'age' provider")
+		}
+	case "secret":
+		if spec.Secret == "" {
+			return nil, fmt.Errorf("'secret' is required for the 'secret' provider")
+		}
+	default:
+		return nil, fmt.Errorf("unknown provider '%v', expected one of: passphrase, age, secret", spec.Provider)
+	}
+	return &spec, nil
+}
+
+// aesGCMSeal encrypts the data with AES-256-GCM, returns the nonce and the encrypted data.
+func aesGCMSeal(key, data []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, data, nil), nil
+}
+
+func aesGCMOpen(key, nonce, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("bad nonce size")
+	}
+	return gcm.Open(nil, nonce, data, nil)
+}
+
+// passphraseKeyProvider wraps the data key with the key derived from the passphrase (scrypt).
+type passphraseKeyProvider struct {
+	passphrase string
+}
+
+const (
+	scryptSaltSize = 16
+	scryptN        = 1 << 15
+	scryptR        = 8
+	scryptP        = 1
+)
+
+func newPassphraseKeyProvider(spec *stateEncryptionSpec, p *Project) (StateKeyProvider, error) {
+	envName := spec.PassphraseEnv
+	if envName == "" {
+		envName = defaultStatePassphraseEnv
+	}
+	passphrase := os.Getenv(envName)
+	if passphrase == "" {
+		return nil, fmt.Errorf("the state passphrase is not set, set the '%v' environment variable", envName)
+	}
+	return &passphraseKeyProvider{passphrase: passphrase}, nil
+}
+
+func (k *passphraseKeyProvider) WrapKey(key []byte) ([]byte, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	kek, err := scrypt.Key([]byte(k.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, wrapped, err := aesGCMSeal(kek, key)
+	if err != nil {
+		return nil, err
+	}
+	res := append(salt, nonce...)
+	return append(res, wrapped...), nil
+}
+
+func (k *passphraseKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	// salt + nonce (12 bytes) + key.
+	if len(wrapped) <= scryptSaltSize+12 {
+		return nil, fmt.Errorf("bad wrapped key size")
+	}
+	salt := wrapped[:scryptSaltSize]
+	nonce := wrapped[scryptSaltSize : scryptSaltSize+12]
+	kek, err := scrypt.Key([]byte(k.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	key, err := aesGCMOpen(kek, nonce, wrapped[scryptSaltSize+12:])
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase")
+	}
+	return key, nil
+}
+
+// ageKeyProvider wraps the data key with the age X25519 key (the identity file created by age-keygen).
+type ageKeyProvider struct {
+	identities []age.Identity
+	recipients []age.Recipient
+}
+
+func newAgeKeyProvider(spec *stateEncryptionSpec, p *Project) (StateKeyProvider, error) {
+	keyFile := spec.KeyFile
+	if strings.HasPrefix(keyFile, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		keyFile = filepath.Join(home, keyFile[2:])
+	} else if !filepath.IsAbs(keyFile) {
+		keyFile = filepath.Join(config.Global.WorkingDir, keyFile)
+	}
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read age key file: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse age key file '%v': %w", keyFile, err)
+	}
+	res := ageKeyProvider{identities: identities}
+	for _, id := range identities {
+		if x25519, ok := id.(*age.X25519Identity); ok {
+			res.recipients = append(res.recipients, x25519.Recipient())
+		}
+	}
+	if len(res.recipients) == 0 {
+		return nil, fmt.Errorf("age key file '%v' does not contain X25519 keys", keyFile)
+	}
+	return &res, nil
+}
+
+func (k *ageKeyProvider) WrapKey(key []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := age.Encrypt(buf, k.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(key); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (k *ageKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(wrapped), k.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// newSecretKeyProvider uses the value of the project secret as the passphrase.
+func newSecretKeyProvider(spec *stateEncryptionSpec, p *Project) (StateKeyProvider, error) {
+	secret, exists := p.secrets[spec.Secret]
+	if !exists {
+		return nil, fmt.Errorf("secret '%v' not found", spec.Secret)
+	}
+	value := secret.Data
+	if spec.SecretKey != "" {
+		data, ok := secret.Data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("secret '%v' is not a map, 'secret_key' can't be used", spec.Secret)
+		}
+		value = data[spec.SecretKey]
+	}
+	passphrase, ok := value.(string)
+	if !ok || passphrase == "" {
+		return nil, fmt.Errorf("secret '%v' should contain the non-empty string value, use 'secret_key' to select the key of the secret", spec.Secret)
+	}
+	return &passphraseKeyProvider{passphrase: passphrase}, nil
+}
+
+func (p *Project) stateKeyProvider() (StateKeyProvider, error) {
+	if p.stateKeyProviderCache != nil {
+		return p.stateKeyProviderCache, nil
+	}
+	factory := stateKeyProviderFactories[p.stateEncryption.Provider]
+	provider, err := factory(p.stateEncryption, p)
+	if err != nil {
+		return nil, fmt.Errorf("state encryption key provider '%v': %w", p.stateEncryption.Provider, err)
+	}
+	p.stateKeyProviderCache = provider
+	return provider, nil
+}
+
+// encryptState encrypts the state data, if the state encryption is enabled in the project config.
+func (p *Project) encryptState(stateData string) (string, error) {
+	if p.stateEncryption == nil {
+		return stateData, nil
+	}
+	provider, err := p.stateKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("encrypt state: %w", err)
+	}
+	nonce, data, err := aesGCMSeal(dataKey, []byte(stateData))
+	if err != nil {
+		return "", fmt.Errorf("encrypt state: %w", err)
+	}
+	return utils.JSONEncodeString(encryptedState{
+		Format:      encryptedStateFormat,
+		KeyProvider: p.stateEncryption.Provider,
+		WrappedKey:  wrappedKey,
+		Nonce:       nonce,
+		Data:        data,
+	})
+}
+
+// parseEncryptedState returns the envelope, if the data is the encrypted state.
+func parseEncryptedState(data []byte) *encryptedState {
+	if !bytes.Contains(data, []byte(`"cdev_encrypted_state"`)) {
+		return nil
+	}
+	envelope := encryptedState{}
+	if err := utils.JSONDecode(data, &envelope); err != nil || envelope.Format == "" {
+		return nil
+	}
+	return &envelope
+}
+
+// decryptState decrypts the state data. Not encrypted states are returned as is.
+func (p *Project) decryptState(data []byte) ([]byte, error) {
+	envelope := parseEncryptedState(data)
+	if envelope == nil {
+		if p.stateEncryption != nil && len(data) > 0 {
+			log.Debugf("The state is not encrypted, it will be encrypted with the next state write")
+		}
+		return data, nil
+	}
+	if envelope.Format != encryptedStateFormat {
+		return nil, fmt.Errorf("decrypt state: unsupported encrypted state format '%v', upgrade cdev", envelope.Format)
+	}
+	if p.stateEncryption == nil {
+		return nil, fmt.Errorf("decrypt state: the state is encrypted with the '%v' key provider, set the 'state_encryption' option in the project config", envelope.KeyProvider)
+	}
+	if envelope.KeyProvider != p.stateEncryption.Provider {
+		return nil, fmt.Errorf("decrypt state: the state is encrypted with the '%v' key provider, but the project config uses '%v'. To change the provider, pull the state with the old provider and push it with the new one", envelope.KeyProvider, p.stateEncryption.Provider)
+	}
+	provider, err := p.stateKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := provider.UnwrapKey(envelope.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt state: unwrap data key: %w", err)
+	}
+	res, err := aesGCMOpen(dataKey, envelope.Nonce, envelope.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt state: %w", err)
+	}
+	return res, nil
+}
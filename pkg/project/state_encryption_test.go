@@ -0,0 +1,136 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+const testStateData = `{"version": "v0.9.0", "units": {"st.unit": {"type": "shell", "env": {"TOKEN": "secret-value"}}}}`
+
+func testEncryptDecrypt(t *testing.T, p *Project) {
+	t.Helper()
+	encrypted, err := p.encryptState(testStateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encrypted, "secret-value") {
+		t.Fatal("encrypted state contains plain text data")
+	}
+	decrypted, err := p.decryptState([]byte(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != testStateData {
+		t.Fatalf("decrypted state mismatch: %s", decrypted)
+	}
+}
+
+func TestStateEncryptionPassphrase(t *testing.T) {
+	t.Setenv("TEST_STATE_PASSPHRASE", "correct horse")
+	p := &Project{stateEncryption: &stateEncryptionSpec{Provider: "passphrase", PassphraseEnv: "TEST_STATE_PASSPHRASE"}}
+	testEncryptDecrypt(t, p)
+
+	encrypted, err := p.encryptState(testStateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_STATE_PASSPHRASE", "wrong")
+	wrongKey := &Project{stateEncryption: p.stateEncryption}
+	if _, err := wrongKey.decryptState([]byte(encrypted)); err == nil {
+		t.Fatal("state decrypted with the wrong passphrase")
+	}
+	noEncryption := &Project{}
+	if _, err := noEncryption.decryptState([]byte(encrypted)); err == nil {
+		t.Fatal("encrypted state decrypted without encryption config")
+	}
+}
+
+func TestStateEncryptionAge(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "state.key")
+	if err := os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Project{stateEncryption: &stateEncryptionSpec{Provider: "age", KeyFile: keyFile}}
+	testEncryptDecrypt(t, p)
+}
+
+func TestStateEncryptionSecret(t *testing.T) {
+	p := &Project{
+		stateEncryption: &stateEncryptionSpec{Provider: "secret", Secret: "state", SecretKey: "passphrase"},
+		secrets: map[string]Secret{
+			"state": {Data: map[string]interface{}{"passphrase": "from-secret"}},
+		},
+	}
+	testEncryptDecrypt(t, p)
+}
+
+func TestStateEncryptionLegacyState(t *testing.T) {
+	t.Setenv("TEST_STATE_PASSPHRASE", "correct horse")
+	p := &Project{stateEncryption: &stateEncryptionSpec{Provider: "passphrase", PassphraseEnv: "TEST_STATE_PASSPHRASE"}}
+	data, err := p.decryptState([]byte(testStateData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testStateData {
+		t.Fatalf("legacy state changed: %s", data)
+	}
+}
+
+func TestPullStateFileMode(t *testing.T) {
+	t.Setenv("TEST_STATE_PASSPHRASE", "correct horse")
+	workDir := config.Global.WorkingDir
+	config.Global.WorkingDir = t.TempDir()
+	defer func() { config.Global.WorkingDir = workDir }()
+
+	p := &Project{stateEncryption: &stateEncryptionSpec{Provider: "passphrase", PassphraseEnv: "TEST_STATE_PASSPHRASE"}}
+	encrypted, err := p.encryptState(testStateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Backends = map[string]Backend{"bk": &stateBackend{offlineBackend: offlineBackend{name: "bk"}, state: encrypted}}
+	p.StateBackendName = "bk"
+
+	// The file pulled before with wider permissions.
+	fileName := filepath.Join(config.Global.WorkingDir, "cdev.state")
+	if err := os.WriteFile(fileName, []byte("old"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PullState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.BackupState(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(config.Global.WorkingDir, "cdev.state*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected the pulled and backup state files, got %v", files)
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%v: expected mode 0600, got %v", filepath.Base(f), info.Mode().Perm())
+		}
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testStateData {
+		t.Errorf("pulled state is not decrypted: %s", data)
+	}
+}
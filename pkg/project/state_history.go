@@ -0,0 +1,241 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// defaultStateHistoryLimit number of the state versions kept in the backend, if project option 'state_history' is not set.
+const defaultStateHistoryLimit = 10
+
+// StateVersion describes one version in the state history. Each cdev run, which changes the state, creates one version.
+type StateVersion struct {
+	Version     int       `json:"version"`
+	SessionID   string    `json:"session_id"`
+	Created     time.Time `json:"created"`
+	User        string    `json:"user"`
+	Hostname    string    `json:"hostname"`
+	CdevVersion string    `json:"cdev_version"`
+	Command     string    `json:"command"`
+	Hash        string    `json:"hash"`
+}
+
+// stateHistory the state history index, versions are sorted from old to new.
+type stateHistory struct {
+	Versions []StateVersion `json:"versions"`
+}
+
+func (h *stateHistory) last() *StateVersion {
+	if len(h.Versions) == 0 {
+		return nil
+	}
+	return &h.Versions[len(h.Versions)-1]
+}
+
+func (h *stateHistory) find(version int) *StateVersion {
+	for i := range h.Versions {
+		if h.Versions[i].Version == version {
+			return &h.Versions[i]
+		}
+	}
+	return nil
+}
+
+// historyBackend returns the state backend, if it supports the state history.
+func (p *Project) historyBackend() (StateHistoryBackend, error) {
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return nil, err
+	}
+	hb, ok := sBk.(StateHistoryBackend)
+	if !ok {
+		return nil, fmt.Errorf("backend '%v' (%v) does not support the state history", sBk.Name(), sBk.Provider())
+	}
+	return hb, nil
+}
+
+func readStateHistory(hb StateHistoryBackend) (*stateHistory, error) {
+	data, err := hb.ReadStateHistory()
+	if err != nil {
+		return nil, err
+	}
+	res := stateHistory{}
+	if data == "" {
+		return &res, nil
+	}
+	err = utils.JSONDecode([]byte(data), &res)
+	if err != nil {
+		return nil, fmt.Errorf("parse state history: %w", err)
+	}
+	sort.Slice(res.Versions, func(i, j int) bool {
+		return res.Versions[i].Version < res.Versions[j].Version
+	})
+	return &res, nil
+}
+
+// saveStateVersion saves the state data to the history. All saves during one cdev run update the same version.
+// The oldest versions are removed according to the 'state_history' project option.
+func (p *Project) saveStateVersion(stateData string) error {
+	if p.stateHistoryLimit <= 0 {
+		return nil
+	}
+	hb, err := p.historyBackend()
+	if err != nil {
+		log.Debugf("Skip saving the state version: %v", err.Error())
+		return nil
+	}
+	history, err := readStateHistory(hb)
+	if err != nil {
+		return err
+	}
+	hash := utils.Md5(stateData)
+	last := history.last()
+	if last != nil && last.SessionID != p.SessionId && last.Hash == hash {
+		// Nothing changed since the previous run.
+		return nil
+	}
+	info := p.newLockInfo()
+	version := StateVersion{
+		Version:     1,
+		SessionID:   p.SessionId,
+		Created:     info.Created,
+		User:        info.User,
+		Hostname:    info.Hostname,
+		CdevVersion: info.CdevVersion,
+		Command:     info.Command,
+		Hash:        hash,
+	}
+	if last != nil && last.SessionID == p.SessionId {
+		version.Version = last.Version
+		*last = version
+	} else {
+		if last != nil {
+			version.Version = last.Version + 1
+		}
+		history.Versions = append(history.Versions, version)
+	}
+	log.Debugf("Saving the state version %v", version.Version)
+	encrypted, err := p.encryptState(stateData)
+	if err != nil {
+		return err
+	}
+	err = hb.WriteStateVersion(version.Version, encrypted)
+	if err != nil {
+		return err
+	}
+	for len(history.Versions) > p.stateHistoryLimit {
+		err = hb.DeleteStateVersion(history.Versions[0].Version)
+		if err != nil {
+			return err
+		}
+		history.Versions = history.Versions[1:]
+	}
+	data, err := utils.JSONEncodeString(history)
+	if err != nil {
+		return err
+	}
+	return hb.WriteStateHistory(data)
+}
+
+// PrintStateHistory prints the list of the state versions.
+func (p *Project) PrintStateHistory() error {
+	hb, err := p.historyBackend()
+	if err != nil {
+		return fmt.Errorf("state history: %w", err)
+	}
+	history, err := readStateHistory(hb)
+	if err != nil {
+		return fmt.Errorf("state history: %w", err)
+	}
+	if len(history.Versions) == 0 {
+		log.Info("The state history is empty")
+		return nil
+	}
+	currentState, err := p.GetState()
+	if err != nil {
+		return fmt.Errorf("state history: %w", err)
+	}
+	currentHash := utils.Md5(string(currentState))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Version", "Created", "Author", "Command", "cdev version", "Current"})
+	for i := len(history.Versions) - 1; i >= 0; i-- {
+		v := history.Versions[i]
+		current := ""
+		if v.Hash == currentHash {
+			current = "*"
+		}
+		table.Append([]string{
+			fmt.Sprint(v.Version),
+			v.Created.Local().Format(time.RFC3339),
+			fmt.Sprintf("%s@%s", v.User, v.Hostname),
+			v.Command,
+			v.CdevVersion,
+			current,
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// RollbackState restores the state version from the history. The restored state is saved to the history as a new version.
+func (p *Project) RollbackState(version int) error {
+	hb, err := p.historyBackend()
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	history, err := readStateHistory(hb)
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	if history.find(version) == nil {
+		return fmt.Errorf("rollback state: version %v not found in the state history, see 'cdev state history'", version)
+	}
+	stateBytes, err := hb.ReadStateVersion(version)
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	decrypted, err := p.decryptState([]byte(stateBytes))
+	if err != nil {
+		return fmt.Errorf("rollback state: version %v: %w", version, err)
+	}
+	stateStr := string(decrypted)
+	restored, _, err := parseState(decrypted)
+	if err != nil {
+		return fmt.Errorf("rollback state: version %v: %w", version, err)
+	}
+	currentStr, err := p.GetState()
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	if len(currentStr) > 0 {
+		current := stateData{}
+		err = utils.JSONDecode(currentStr, &current)
+		if err == nil && current.ProjectUUID != "" && restored.ProjectUUID != "" && current.ProjectUUID != restored.ProjectUUID {
+			return fmt.Errorf("rollback state: project UUID mismatch: version %v '%v', current state '%v'", version, restored.ProjectUUID, current.ProjectUUID)
+		}
+	}
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	log.Infof("Restoring the state version %v", version)
+	encrypted, err := p.encryptState(stateStr)
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	err = sBk.WriteState(encrypted)
+	if err != nil {
+		return fmt.Errorf("rollback state: %w", err)
+	}
+	err = p.saveStateVersion(stateStr)
+	if err != nil {
+		log.Warnf("Saving the state version: %v", err.Error())
+	}
+	return nil
+}
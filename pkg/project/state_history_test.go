@@ -0,0 +1,157 @@
+package project_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/backend/memory"
+	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// newMemoryProject creates the empty project with the memory state backend.
+func newMemoryProject(t *testing.T) (*project.Project, project.Backend) {
+	p := project.NewEmptyProject()
+	bk, err := (&memory.Factory{}).New([]byte("{}"), "mem", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Backends = map[string]project.Backend{"mem": bk}
+	p.StateBackendName = "mem"
+	p.UUID = "1234"
+	return p, bk
+}
+
+// testState returns the state data with the unit, the data differs by the unit name.
+func testState(uuid, unit string) string {
+	return fmt.Sprintf(`{"version": "v0.10.0", "schema_version": 1, "project_uuid": "%v", "unit_links": {}, "units": {"infra.%v": null}}`, uuid, unit)
+}
+
+// historyVersions returns the version numbers of the state history.
+func historyVersions(t *testing.T, bk project.Backend) []int {
+	data, err := bk.(project.StateHistoryBackend).ReadStateHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := struct {
+		Versions []project.StateVersion `json:"versions"`
+	}{}
+	if err := utils.JSONDecode([]byte(data), &history); err != nil {
+		t.Fatal(err)
+	}
+	res := []int{}
+	for _, v := range history.Versions {
+		res = append(res, v.Version)
+	}
+	return res
+}
+
+func TestSaveStateVersion(t *testing.T) {
+	p, bk := newMemoryProject(t)
+	hb := bk.(project.StateHistoryBackend)
+	steps := []struct {
+		name     string
+		session  string
+		state    string
+		versions string
+	}{
+		{name: "first run", session: "s1", state: testState("1234", "a"), versions: "[1]"},
+		{name: "same run updates the version", session: "s1", state: testState("1234", "b"), versions: "[1]"},
+		{name: "not changed state", session: "s2", state: testState("1234", "b"), versions: "[1]"},
+		{name: "next run", session: "s3", state: testState("1234", "c"), versions: "[1 2]"},
+	}
+	for _, s := range steps {
+		p.SessionId = s.session
+		if err := p.SaveStateVersion(s.state); err != nil {
+			t.Fatalf("%v: %v", s.name, err)
+		}
+		if versions := fmt.Sprint(historyVersions(t, bk)); versions != s.versions {
+			t.Errorf("%v: expected versions %v, got %v", s.name, s.versions, versions)
+		}
+	}
+	for version, expected := range map[int]string{1: testState("1234", "b"), 2: testState("1234", "c")} {
+		data, err := hb.ReadStateVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data != expected {
+			t.Errorf("version %v: expected %v, got %v", version, expected, data)
+		}
+	}
+
+	p.SetStateHistoryLimit(0)
+	p.SessionId = "s4"
+	if err := p.SaveStateVersion(testState("1234", "d")); err != nil {
+		t.Fatal(err)
+	}
+	if versions := fmt.Sprint(historyVersions(t, bk)); versions != "[1 2]" {
+		t.Errorf("the history is disabled, expected versions [1 2], got %v", versions)
+	}
+}
+
+func TestStateHistoryPruning(t *testing.T) {
+	p, bk := newMemoryProject(t)
+	hb := bk.(project.StateHistoryBackend)
+	runs := project.DefaultStateHistoryLimit + 3
+	for i := 1; i <= runs; i++ {
+		p.SessionId = fmt.Sprintf("s%d", i)
+		if err := p.SaveStateVersion(testState("1234", fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions := historyVersions(t, bk)
+	if len(versions) != project.DefaultStateHistoryLimit || versions[0] != 4 || versions[len(versions)-1] != runs {
+		t.Fatalf("expected versions 4..%v, got %v", runs, versions)
+	}
+	for version := 1; version <= 3; version++ {
+		if _, err := hb.ReadStateVersion(version); !errors.Is(err, project.ErrStateVersionNotFound) {
+			t.Errorf("version %v should be removed, got %v", version, err)
+		}
+	}
+	if _, err := hb.ReadStateVersion(4); err != nil {
+		t.Errorf("version 4 should be kept: %v", err)
+	}
+}
+
+func TestRollbackState(t *testing.T) {
+	p, bk := newMemoryProject(t)
+	for i, unit := range []string{"a", "b"} {
+		p.SessionId = fmt.Sprintf("s%d", i)
+		if err := bk.WriteState(testState("1234", unit)); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SaveStateVersion(testState("1234", unit)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.SessionId = "rollback"
+	if err := p.RollbackState(5); err == nil || !strings.Contains(err.Error(), "version 5 not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := p.RollbackState(1); err != nil {
+		t.Fatal(err)
+	}
+	state, err := bk.ReadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != testState("1234", "a") {
+		t.Errorf("expected the state of version 1, got %v", state)
+	}
+	if versions := fmt.Sprint(historyVersions(t, bk)); versions != "[1 2 3]" {
+		t.Errorf("the restored state should be saved as a new version, got %v", versions)
+	}
+
+	// The version of other project.
+	p.SessionId = "other"
+	if err := p.SaveStateVersion(testState("5678", "c")); err != nil {
+		t.Fatal(err)
+	}
+	p.SessionId = "rollback-other"
+	if err := p.RollbackState(4); err == nil || !strings.Contains(err.Error(), "project UUID mismatch") {
+		t.Errorf("expected UUID mismatch error, got %v", err)
+	}
+}
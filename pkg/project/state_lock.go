@@ -0,0 +1,221 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// lockRetryInterval delay between the lock attempts when --lock-timeout is set.
+const lockRetryInterval = 5 * time.Second
+
+// LockInfo describes the holder of the state lock.
+type LockInfo struct {
+	ID          string    `json:"id"`
+	User        string    `json:"user"`
+	Hostname    string    `json:"hostname"`
+	PID         int       `json:"pid"`
+	CdevVersion string    `json:"cdev_version"`
+	Command     string    `json:"command"`
+	Created     time.Time `json:"created"`
+	// TTL the lock is considered stale after this duration. Empty means the lock never expires.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// newLockInfo creates the lock info of the current process.
+func (p *Project) newLockInfo() *LockInfo {
+	info := LockInfo{
+		ID:          p.SessionId,
+		PID:         os.Getpid(),
+		CdevVersion: config.Global.Version,
+		Command:     config.Global.Command,
+		Created:     time.Now().UTC(),
+	}
+	if usr, err := user.Current(); err == nil {
+		info.User = usr.Username
+	}
+	info.Hostname, _ = os.Hostname()
+	if config.Global.LockTTL > 0 {
+		info.TTL = config.Global.LockTTL.String()
+	}
+	return &info
+}
+
+// ParseLockInfo parses the lock data saved by backend. Locks created by old cdev versions contain only
+// the session ID or nothing, they are returned with the empty holder fields.
+func ParseLockInfo(data string) *LockInfo {
+	info := LockInfo{}
+	if err := utils.JSONDecode([]byte(data), &info); err != nil {
+		return &LockInfo{ID: data}
+	}
+	return &info
+}
+
+// Age returns the lock age. Zero if unknown.
+func (l *LockInfo) Age() time.Duration {
+	if l.Created.IsZero() {
+		return 0
+	}
+	return time.Since(l.Created).Round(time.Second)
+}
+
+// IsStale returns true if the lock is older than its TTL.
+func (l *LockInfo) IsStale() bool {
+	if l.TTL == "" {
+		return false
+	}
+	ttl, err := time.ParseDuration(l.TTL)
+	if err != nil || ttl <= 0 {
+		return false
+	}
+	return l.Age() > ttl
+}
+
+// String returns short description of the lock holder.
+func (l *LockInfo) String() string {
+	if l.Created.IsZero() {
+		return "unknown holder (the lock was created by an older cdev version)"
+	}
+	return fmt.Sprintf("%s@%s (PID %d, cdev %s, command '%s'), acquired at %s (%v ago)",
+		l.User, l.Hostname, l.PID, l.CdevVersion, l.Command, l.Created.Format(time.RFC3339), l.Age())
+}
+
+// warnIfStale prints warning if the lock is older than its TTL.
+func (l *LockInfo) warnIfStale() {
+	if l.IsStale() {
+		log.Warnf("The state lock is older than its TTL (%v), the holder may have crashed. If you are sure that nobody works with the project, use command 'cdev state unlock' to remove the lock", l.TTL)
+	}
+}
+
+func (p *Project) stateBackend() (Backend, error) {
+	if p.StateBackendName == "" {
+		return nil, fmt.Errorf("internal error: empty project backend")
+	}
+	sBk, ok := p.Backends[p.StateBackendName]
+	if !ok {
+		return nil, fmt.Errorf("state backend '%v' does not found", p.StateBackendName)
+	}
+	return sBk, nil
+}
+
+// LockState locks the project state. If the state is locked by another process, it retries until
+// the --lock-timeout is reached.
+func (p *Project) LockState(ctx context.Context) error {
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("lock state: %w", err)
+	}
+	lockData, err := utils.JSONEncodeString(p.newLockInfo())
+	if err != nil {
+		return fmt.Errorf("lock state: %w", err)
+	}
+	deadline := time.Now().Add(config.Global.LockTimeout)
+	for attempt := 1; ; attempt++ {
+		err = sBk.LockState(lockData)
+		if !errors.Is(err, ErrStateLocked) {
+			return err
+		}
+		info, readErr := p.ReadLockInfo()
+		if readErr != nil {
+			log.Debugf("Can't read the state lock info: %v", readErr.Error())
+		}
+		if info != nil {
+			if attempt == 1 {
+				info.warnIfStale()
+			}
+			err = fmt.Errorf("%w\nLocked by: %s", err, info)
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return err
+		}
+		if wait > lockRetryInterval {
+			wait = lockRetryInterval
+		}
+		if attempt == 1 {
+			log.Warnf("The state is locked, waiting for the lock up to %v...", config.Global.LockTimeout)
+			if info != nil {
+				log.Infof("Locked by: %s", info)
+			}
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("lock state: interrupted: %w", ctx.Err())
+		}
+	}
+}
+
+// ReadLockInfo returns the state lock info, nil if the state is not locked.
+func (p *Project) ReadLockInfo() (*LockInfo, error) {
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return nil, fmt.Errorf("read lock info: %w", err)
+	}
+	data, locked, err := sBk.ReadLock()
+	if err != nil {
+		return nil, fmt.Errorf("read lock info: %w", err)
+	}
+	if !locked {
+		return nil, nil
+	}
+	return ParseLockInfo(data), nil
+}
+
+func (p *Project) UnLockState() error {
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("unlock state: %w", err)
+	}
+	return sBk.UnlockState()
+}
+
+// PrintLockInfo prints the state lock holder. The holder is unknown for the backends, which can't read the lock
+// without taking it.
+func (p *Project) PrintLockInfo() error {
+	sBk, err := p.stateBackend()
+	if err != nil {
+		return fmt.Errorf("read lock info: %w", err)
+	}
+	if prober, ok := sBk.(LockProbeBackend); ok && prober.ProbesLock() {
+		log.Warnf("The state lock holder is unknown: backend '%v' (%v) can't read the lock without locking the state. The holder is shown when the state is locked by other commands, like 'cdev apply'", sBk.Name(), sBk.Provider())
+		return nil
+	}
+	info, err := p.ReadLockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		log.Info("The state is not locked")
+		return nil
+	}
+	if info.Created.IsZero() {
+		log.Warnf("The state is locked by %s", info)
+		return nil
+	}
+	fmt.Println("State lock:")
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.AppendBulk([][]string{
+		{"ID", info.ID},
+		{"User", info.User},
+		{"Hostname", info.Hostname},
+		{"PID", fmt.Sprint(info.PID)},
+		{"cdev version", info.CdevVersion},
+		{"Command", info.Command},
+		{"Acquired", info.Created.Format(time.RFC3339)},
+		{"Age", info.Age().String()},
+		{"TTL", info.TTL},
+	})
+	table.Render()
+	info.warnIfStale()
+	return nil
+}
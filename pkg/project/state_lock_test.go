@@ -0,0 +1,124 @@
+package project_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+func TestLockState(t *testing.T) {
+	defer func(timeout, ttl time.Duration) {
+		config.Global.LockTimeout, config.Global.LockTTL = timeout, ttl
+	}(config.Global.LockTimeout, config.Global.LockTTL)
+	config.Global.LockTimeout = 0
+	config.Global.LockTTL = time.Hour
+
+	holder, _ := newMemoryProject(t)
+	holder.SessionId = "holder"
+	if err := holder.LockState(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := holder.ReadLockInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.ID != "holder" || info.TTL != "1h0m0s" || info.Created.IsZero() {
+		t.Fatalf("unexpected lock info: %+v", info)
+	}
+
+	// The other process uses the same backend.
+	p := project.NewEmptyProject()
+	p.Backends = holder.Backends
+	p.StateBackendName = holder.StateBackendName
+	p.SessionId = "waiting"
+
+	err = p.LockState(context.Background())
+	if !errors.Is(err, project.ErrStateLocked) || !strings.Contains(err.Error(), "Locked by: ") {
+		t.Errorf("expected locked error with the holder, got %v", err)
+	}
+
+	config.Global.LockTimeout = 300 * time.Millisecond
+	start := time.Now()
+	err = p.LockState(context.Background())
+	if !errors.Is(err, project.ErrStateLocked) {
+		t.Errorf("expected locked error after the lock timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < config.Global.LockTimeout {
+		t.Errorf("expected waiting for the lock timeout, returned after %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = p.LockState(ctx)
+	if err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("expected interrupted error, got %v", err)
+	}
+
+	// The lock is released while waiting.
+	config.Global.LockTimeout = 500 * time.Millisecond
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		holder.UnLockState()
+	}()
+	if err := p.LockState(context.Background()); err != nil {
+		t.Fatalf("expected the lock after the holder released it, got %v", err)
+	}
+	info, err = p.ReadLockInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.ID != "waiting" {
+		t.Errorf("unexpected lock info: %+v", info)
+	}
+}
+
+func TestLockInfoStale(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  project.LockInfo
+		stale bool
+	}{
+		{name: "no ttl", info: project.LockInfo{Created: time.Now().Add(-time.Hour)}},
+		{name: "fresh", info: project.LockInfo{Created: time.Now(), TTL: "1h"}},
+		{name: "expired", info: project.LockInfo{Created: time.Now().Add(-2 * time.Hour), TTL: "1h"}, stale: true},
+		{name: "bad ttl", info: project.LockInfo{Created: time.Now().Add(-2 * time.Hour), TTL: "soon"}},
+		{name: "unknown age", info: project.LockInfo{TTL: "1m"}},
+	}
+	for _, tt := range tests {
+		if stale := tt.info.IsStale(); stale != tt.stale {
+			t.Errorf("%v: expected stale %v, got %v", tt.name, tt.stale, stale)
+		}
+	}
+}
+
+func TestParseLockInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		id     string
+		holder string
+	}{
+		{name: "empty legacy lock", data: "", id: "", holder: "unknown holder"},
+		{name: "legacy session id", data: "a1b2c3", id: "a1b2c3", holder: "unknown holder"},
+		{
+			name:   "lock info",
+			data:   `{"id": "a1b2c3", "user": "dev", "hostname": "laptop", "pid": 42, "cdev_version": "v0.10.0", "command": "apply", "created": "2024-01-02T03:04:05Z", "ttl": "1h"}`,
+			id:     "a1b2c3",
+			holder: "dev@laptop (PID 42, cdev v0.10.0, command 'apply'), acquired at 2024-01-02T03:04:05Z",
+		},
+	}
+	for _, tt := range tests {
+		info := project.ParseLockInfo(tt.data)
+		if info.ID != tt.id {
+			t.Errorf("%v: expected ID '%v', got '%v'", tt.name, tt.id, info.ID)
+		}
+		if !strings.HasPrefix(info.String(), tt.holder) {
+			t.Errorf("%v: expected holder '%v', got '%v'", tt.name, tt.holder, info.String())
+		}
+	}
+}
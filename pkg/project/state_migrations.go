@@ -0,0 +1,116 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+// stateMigration upgrades the raw state data to the next schema version.
+type stateMigration struct {
+	Description string
+	Migrate     func(state map[string]interface{}) error
+}
+
+// stateMigrations ordered list of the state schema migrations. The migration with index N upgrades the state
+// from the schema version N to N+1. The states without the schema version have version 0.
+// Add new migrations to the end of the list only.
+var stateMigrations = []stateMigration{
+	{
+		Description: "remove empty units, rename deprecated unit type 'terraform' to 'tfmodule'",
+		Migrate:     migrateStateV1,
+	},
+}
+
+// stateSchemaVersion returns the state schema version of the current cdev version.
+func stateSchemaVersion() int {
+	return len(stateMigrations)
+}
+
+func migrateStateV1(state map[string]interface{}) error {
+	units, ok := state["units"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, unitState := range units {
+		if unitState == nil {
+			delete(units, key)
+			continue
+		}
+		unitMap, ok := unitState.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unit '%v': unexpected state format", key)
+		}
+		if unitMap["type"] == "terraform" {
+			unitMap["type"] = "tfmodule"
+		}
+	}
+	return nil
+}
+
+// parseState parses the state data and migrates it to the current schema version.
+// Returns the schema version of the data before the migration.
+func parseState(data []byte) (*stateData, int, error) {
+	st := stateData{
+		UnitLinks: &UnitLinksT{},
+		Units:     map[string]interface{}{},
+	}
+	if len(data) == 0 {
+		return &st, stateSchemaVersion(), nil
+	}
+	raw := map[string]interface{}{}
+	err := utils.JSONDecode(data, &raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	version := 0
+	if v, exists := raw["schema_version"]; exists {
+		fv, ok := v.(float64)
+		if !ok || fv < 0 || fv != float64(int(fv)) {
+			return nil, 0, fmt.Errorf("bad state schema version '%v'", v)
+		}
+		version = int(fv)
+	}
+	if version > stateSchemaVersion() {
+		return nil, version, fmt.Errorf("the state has schema version %v and was written by a newer cdev version (%v), this cdev version (%v) supports schema version %v or older. Upgrade cdev", version, raw["version"], config.Global.Version, stateSchemaVersion())
+	}
+	for v := version; v < stateSchemaVersion(); v++ {
+		log.Debugf("Migrating state schema from version %v to %v: %v", v, v+1, stateMigrations[v].Description)
+		err = stateMigrations[v].Migrate(raw)
+		if err != nil {
+			return nil, version, fmt.Errorf("migrate state schema from version %v to %v: %w", v, v+1, err)
+		}
+		raw["schema_version"] = v + 1
+	}
+	migrated, err := utils.JSONEncode(raw)
+	if err != nil {
+		return nil, version, err
+	}
+	err = utils.JSONDecode(migrated, &st)
+	if err != nil {
+		return nil, version, err
+	}
+	if st.UnitLinks == nil {
+		st.UnitLinks = &UnitLinksT{}
+	}
+	if st.Units == nil {
+		st.Units = map[string]interface{}{}
+	}
+	return &st, version, nil
+}
+
+// decodeRemoteState parses the remote state data and migrates it to the current schema version.
+// The migrated state is saved with the next state write, the remote state is backed up before it.
+func (p *Project) decodeRemoteState(data []byte) (*stateData, error) {
+	st, version, err := parseState(data)
+	if err != nil {
+		return nil, err
+	}
+	if version < stateSchemaVersion() {
+		log.Debugf("The state has schema version %v, it will be migrated to version %v on save", version, stateSchemaVersion())
+		p.stateMigrated = true
+	}
+	return st, nil
+}
@@ -0,0 +1,99 @@
+package project
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+func TestParseStateMigration(t *testing.T) {
+	data := `{"version": "v0.9.0", "units": {"st.old": {"type": "terraform", "name": "old"}, "st.empty": null}}`
+	st, version, err := parseState([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Errorf("expected original schema version 0, got %v", version)
+	}
+	if st.SchemaVersion != stateSchemaVersion() {
+		t.Errorf("expected schema version %v after migration, got %v", stateSchemaVersion(), st.SchemaVersion)
+	}
+	if _, exists := st.Units["st.empty"]; exists {
+		t.Error("empty unit was not removed")
+	}
+	if unitType := st.Units["st.old"].(map[string]interface{})["type"]; unitType != "tfmodule" {
+		t.Errorf("expected unit type 'tfmodule', got '%v'", unitType)
+	}
+}
+
+func TestParseStateNewerSchema(t *testing.T) {
+	_, _, err := parseState([]byte(`{"version": "v99.0.0", "schema_version": 1000, "units": {}}`))
+	if err == nil || !strings.Contains(err.Error(), "newer cdev version") {
+		t.Fatalf("expected newer schema error, got %v", err)
+	}
+}
+
+func TestParseStateEmpty(t *testing.T) {
+	st, version, err := parseState(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != stateSchemaVersion() || st.UnitLinks == nil || st.Units == nil {
+		t.Errorf("unexpected empty state: version %v, %+v", version, st)
+	}
+}
+
+// writableStateBackend test backend, which keeps the written state and counts the writes.
+type writableStateBackend struct {
+	stateBackend
+	writes int
+}
+
+func (b *writableStateBackend) WriteState(state string) error {
+	b.state = state
+	b.writes++
+	return nil
+}
+
+func TestMigrationBackupOnWrite(t *testing.T) {
+	workDir := config.Global.WorkingDir
+	config.Global.WorkingDir = t.TempDir()
+	defer func() { config.Global.WorkingDir = workDir }()
+
+	bk := &writableStateBackend{stateBackend: stateBackend{
+		offlineBackend: offlineBackend{name: "bk"},
+		state:          `{"version": "v0.9.0", "units": {}}`,
+	}}
+	p := NewEmptyProject()
+	p.Backends = map[string]Backend{"bk": bk}
+	p.StateBackendName = "bk"
+	p.stateHistoryLimit = 0
+	backups := func() int {
+		files, err := filepath.Glob(filepath.Join(config.Global.WorkingDir, "cdev.state.backup.*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+
+	st, err := p.readStateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backups() != 0 {
+		t.Fatal("the state should not be backed up on read")
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.writeStateData(st, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups() != 1 || bk.writes != 2 {
+		t.Errorf("expected one backup before the first write, got %v backups and %v writes", backups(), bk.writes)
+	}
+	if !strings.Contains(bk.state, `"schema_version": 1`) {
+		t.Errorf("the migrated state was not written: %v", bk.state)
+	}
+}
@@ -0,0 +1,103 @@
+package project_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+func TestPushState(t *testing.T) {
+	workDir := config.Global.WorkingDir
+	config.Global.WorkingDir = t.TempDir()
+	defer func() { config.Global.WorkingDir = workDir }()
+
+	remoteState := `{"version": "v0.10.0", "schema_version": 1, "project_uuid": "1234", "unit_links": {}, "units": {}}`
+	tests := []struct {
+		name   string
+		state  string
+		errMsg string
+	}{
+		{
+			name:   "other project",
+			state:  `{"version": "v0.10.0", "schema_version": 1, "project_uuid": "5678", "unit_links": {}, "units": {}}`,
+			errMsg: "project UUID mismatch: file '5678', remote state '1234'",
+		},
+		{
+			name:   "unknown unit type",
+			state:  `{"version": "v0.10.0", "schema_version": 1, "project_uuid": "1234", "unit_links": {}, "units": {"infra.a": {"type": "nope"}}}`,
+			errMsg: "unit 'infra.a': unknown unit type 'nope'",
+		},
+		{
+			name:   "bad unit state",
+			state:  `{"version": "v0.10.0", "schema_version": 1, "project_uuid": "1234", "unit_links": {}, "units": {"infra.a": "data"}}`,
+			errMsg: "unit 'infra.a': unexpected state format",
+		},
+		{
+			name:   "not json",
+			state:  `units: {}`,
+			errMsg: "push state: parse",
+		},
+		{
+			name:  "valid",
+			state: `{"version": "v0.10.1", "schema_version": 1, "project_uuid": "1234", "unit_links": {}, "units": {}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, bk := newMemoryProject(t)
+			if err := bk.WriteState(remoteState); err != nil {
+				t.Fatal(err)
+			}
+			fileName := filepath.Join(t.TempDir(), "cdev.state")
+			if err := os.WriteFile(fileName, []byte(tt.state), 0600); err != nil {
+				t.Fatal(err)
+			}
+			backupsBefore, err := filepath.Glob(filepath.Join(config.Global.WorkingDir, "cdev.state.backup.*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.PushState(fileName)
+
+			state, readErr := bk.ReadState()
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			backups, globErr := filepath.Glob(filepath.Join(config.Global.WorkingDir, "cdev.state.backup.*"))
+			if globErr != nil {
+				t.Fatal(globErr)
+			}
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error with '%v', got '%v'", tt.errMsg, err)
+				}
+				if state != remoteState {
+					t.Errorf("the remote state should not be changed, got %v", state)
+				}
+				if len(backups) != len(backupsBefore) {
+					t.Errorf("the rejected state should not create a backup")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != tt.state {
+				t.Errorf("expected the pushed state, got %v", state)
+			}
+			if len(backups) != len(backupsBefore)+1 {
+				t.Fatalf("expected one backup, got %v", len(backups)-len(backupsBefore))
+			}
+			backup, err := os.ReadFile(backups[len(backups)-1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(backup) != remoteState {
+				t.Errorf("the backup should contain the previous remote state, got %s", backup)
+			}
+		})
+	}
+}
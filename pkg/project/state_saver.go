@@ -0,0 +1,75 @@
+package project
+
+import (
+	"time"
+
+	"github.com/apex/log"
+)
+
+// stateSaveDebounce time to collect the results of units finished one after another into a single state write.
+const stateSaveDebounce = 2 * time.Second
+
+// stateSaver writes the state in background while units are executed. Writes are serialized by the state mutex
+// and debounced: save requests received during stateSaveDebounce are merged into one write.
+type stateSaver struct {
+	request chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// StartAutoSave starts background state saving. Each RequestSave call persists the state shortly after,
+// so the results of finished units are not lost if cdev process dies.
+func (sp *StateProject) StartAutoSave() {
+	if sp.saver != nil {
+		return
+	}
+	sp.saver = &stateSaver{
+		request: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go sp.autoSaveLoop(sp.saver)
+}
+
+// StopAutoSave stops background state saving and waits for the write in progress.
+// The state should be saved by the caller after that.
+func (sp *StateProject) StopAutoSave() {
+	if sp.saver == nil {
+		return
+	}
+	close(sp.saver.done)
+	<-sp.saver.stopped
+	sp.saver = nil
+}
+
+// RequestSave schedules the background state writing. Does nothing if auto save was not started.
+func (sp *StateProject) RequestSave() {
+	if sp.saver == nil {
+		return
+	}
+	select {
+	case sp.saver.request <- struct{}{}:
+	default:
+		// Write is already scheduled.
+	}
+}
+
+func (sp *StateProject) autoSaveLoop(s *stateSaver) {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.request:
+		case <-s.done:
+			return
+		}
+		select {
+		case <-time.After(stateSaveDebounce):
+		case <-s.done:
+			return
+		}
+		err := sp.saveState(false)
+		if err != nil {
+			log.Warnf("Saving state after unit completion: %v", err)
+		}
+	}
+}
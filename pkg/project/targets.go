@@ -0,0 +1,96 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+// TargetsFilter returns a new planning status which contains only the targeted units and the units they are bound with.
+// If destroy is false (apply), all upstream dependencies of targeted units are added. If destroy is true,
+// all downstream dependents of targeted units are added, since they can't stay without the destroyed unit.
+func (s *ProjectPlanningStatus) TargetsFilter(targets *config.TargetUnits, destroy bool) (*ProjectPlanningStatus, error) {
+	if targets.IsEmpty() {
+		return s, nil
+	}
+	if err := targets.Validate(); err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, us := range s.units {
+		if targets.Check(us.UnitPtr.Key()) {
+			selected[us.UnitPtr.Key()] = true
+		}
+	}
+	for _, target := range *targets {
+		tg := config.NewTargetsChecker([]string{target})
+		found := false
+		for key := range selected {
+			if tg.Check(key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("target '%v' does not match any unit in the plan", target)
+		}
+	}
+	if destroy {
+		s.addDependents(selected)
+	} else {
+		visited := map[string]bool{}
+		for _, us := range s.units {
+			if targets.Check(us.UnitPtr.Key()) {
+				addDependenciesRecursive(us.UnitPtr, selected, visited)
+			}
+		}
+	}
+	res := ProjectPlanningStatus{
+		units: make([]*UnitPlanningStatus, 0),
+	}
+	for _, us := range s.units {
+		if !selected[us.UnitPtr.Key()] {
+			log.Debugf("Unit '%v' is out of targets, skip", us.UnitPtr.Key())
+			continue
+		}
+		res.units = append(res.units, us)
+	}
+	return &res, nil
+}
+
+// addDependenciesRecursive adds keys of all unit dependencies to the selected set.
+func addDependenciesRecursive(u Unit, selected, visited map[string]bool) {
+	if visited[u.Key()] {
+		return
+	}
+	visited[u.Key()] = true
+	for _, dep := range u.Dependencies().Slice() {
+		selected[dep.UnitKey()] = true
+		if dep.Unit != nil {
+			addDependenciesRecursive(dep.Unit, selected, visited)
+		}
+	}
+}
+
+// addDependents adds keys of all units, which depend on selected units (directly or transitively).
+func (s *ProjectPlanningStatus) addDependents(selected map[string]bool) {
+	for {
+		added := 0
+		for _, us := range s.units {
+			if selected[us.UnitPtr.Key()] {
+				continue
+			}
+			for _, dep := range us.UnitPtr.Dependencies().Slice() {
+				if selected[dep.UnitKey()] {
+					selected[us.UnitPtr.Key()] = true
+					added++
+					break
+				}
+			}
+		}
+		if added == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package project
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+// newTargetsTestPlanning returns the planning status of the units infra.a <- infra.b <- infra.c,
+// apps.web (depends on infra.b) and the independent unit apps.cron.
+func newTargetsTestPlanning(t *testing.T, op UnitOperation) *ProjectPlanningStatus {
+	p := &Project{UnitLinks: &UnitLinksT{}}
+	stacks := map[string]*Stack{
+		"infra": {Name: "infra", ProjectPtr: p},
+		"apps":  {Name: "apps", ProjectPtr: p},
+	}
+	units := map[string]*testUnit{}
+	planning := &ProjectPlanningStatus{}
+	for _, key := range []string{"infra.a", "infra.b", "infra.c", "apps.web", "apps.cron"} {
+		stackName, unitName, _ := strings.Cut(key, ".")
+		units[key] = newTestUnit(unitName, stacks[stackName])
+		planning.Add(units[key], op, "", false)
+	}
+	for unit, dep := range map[string]string{"infra.b": "infra.a", "infra.c": "infra.b", "apps.web": "infra.b"} {
+		stackName, unitName, _ := strings.Cut(dep, ".")
+		link := &ULinkT{Unit: units[dep], TargetStackName: stackName, TargetUnitName: unitName, LinkType: CustomLinkType}
+		if _, err := units[unit].deps.Set(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return planning
+}
+
+func TestTargetsFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       UnitOperation
+		targets  []string
+		expected string
+	}{
+		{name: "no targets", op: Apply, expected: "apps.cron,apps.web,infra.a,infra.b,infra.c"},
+		{name: "apply adds dependencies", op: Apply, targets: []string{"infra.c"}, expected: "infra.a,infra.b,infra.c"},
+		{name: "apply other stack dependencies", op: Apply, targets: []string{"apps.web"}, expected: "apps.web,infra.a,infra.b"},
+		{name: "apply stack", op: Apply, targets: []string{"apps"}, expected: "apps.cron,apps.web,infra.a,infra.b"},
+		{name: "apply independent unit", op: Apply, targets: []string{"apps.cron"}, expected: "apps.cron"},
+		{name: "destroy adds dependents", op: Destroy, targets: []string{"infra.b"}, expected: "apps.web,infra.b,infra.c"},
+		{name: "destroy root", op: Destroy, targets: []string{"infra.a"}, expected: "apps.web,infra.a,infra.b,infra.c"},
+		{name: "destroy leaf", op: Destroy, targets: []string{"infra.c", "apps.web"}, expected: "apps.web,infra.c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planning := newTargetsTestPlanning(t, tt.op)
+			res, err := planning.TargetsFilter(config.NewTargetsChecker(tt.targets), tt.op == Destroy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := []string{}
+			for _, us := range res.Slice() {
+				keys = append(keys, us.UnitPtr.Key())
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+}
+
+func TestTargetsFilterErrors(t *testing.T) {
+	tests := []struct {
+		targets []string
+		errMsg  string
+	}{
+		{targets: []string{"infra.a", "infra.nope"}, errMsg: "target 'infra.nope' does not match any unit"},
+		{targets: []string{"nope"}, errMsg: "target 'nope' does not match any unit"},
+		{targets: []string{"infra.a.b"}, errMsg: "bad target 'infra.a.b'"},
+	}
+	for _, tt := range tests {
+		planning := newTargetsTestPlanning(t, Apply)
+		_, err := planning.TargetsFilter(config.NewTargetsChecker(tt.targets), false)
+		if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("%v: expected error with '%v', got '%v'", tt.targets, tt.errMsg, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const testUnitType = "test"
+
+// testUnit minimal unit for the project tests. The 'depends_on' units are the custom links, the 'data'
+// is scanned for the output markers on Prepare.
+type testUnit struct {
+	name       string
+	stack      *Stack
+	deps       *UnitLinksT
+	data       map[string]interface{}
+	mux        sync.Mutex
+	tainted    bool
+	execStatus ExecutionStatus
+	execErr    error
+}
+
+type testUnitFactory struct{}
+
+func (f *testUnitFactory) New(spec map[string]interface{}, stack *Stack) (Unit, error) {
+	name, _ := spec["name"].(string)
+	u := newTestUnit(name, stack)
+	u.data, _ = spec["data"].(map[string]interface{})
+	deps, _ := spec["depends_on"].([]interface{})
+	for _, dep := range deps {
+		stackName, unitName, found := strings.Cut(dep.(string), ".")
+		if !found {
+			return nil, fmt.Errorf("bad dependency '%v'", dep)
+		}
+		if stackName == "this" {
+			stackName = stack.Name
+		}
+		link := &ULinkT{TargetStackName: stackName, TargetUnitName: unitName, LinkType: CustomLinkType}
+		stack.ProjectPtr.UnitLinks.Set(link)
+		u.deps.Set(link)
+	}
+	return u, nil
+}
+
+func (f *testUnitFactory) NewFromState(map[string]interface{}, string, *StateProject) (Unit, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func init() {
+	UnitFactoriesMap[testUnitType] = &testUnitFactory{}
+}
+
+func newTestUnit(name string, stack *Stack) *testUnit {
+	return &testUnit{name: name, stack: stack, deps: &UnitLinksT{}, execStatus: Backlog}
+}
+
+func (u *testUnit) Name() string              { return u.name }
+func (u *testUnit) Stack() *Stack             { return u.stack }
+func (u *testUnit) Project() *Project         { return u.stack.ProjectPtr }
+func (u *testUnit) Backend() Backend          { return u.stack.Backend }
+func (u *testUnit) Key() string               { return u.stack.Name + "." + u.name }
+func (u *testUnit) Dependencies() *UnitLinksT { return u.deps }
+
+func (u *testUnit) Prepare() error {
+	for _, link := range u.deps.ByLinkTypes(CustomLinkType).Slice() {
+		if err := link.InitUnitPtr(u.Project()); err != nil {
+			return err
+		}
+	}
+	return ScanMarkers(u.data, OutputsScanner, u)
+}
+
+func (u *testUnit) Build() error                      { return nil }
+func (u *testUnit) Init(ctx context.Context) error    { return nil }
+func (u *testUnit) Apply(ctx context.Context) error   { return nil }
+func (u *testUnit) Plan(ctx context.Context) error    { return nil }
+func (u *testUnit) Destroy(ctx context.Context) error { return nil }
+func (u *testUnit) GetState() Unit                    { return u }
+func (u *testUnit) GetDiffData() interface{}          { return u.data }
+func (u *testUnit) GetStateDiffData() interface{}     { return u.data }
+
+func (u *testUnit) LoadState(interface{}, string, *StateProject) error { return nil }
+
+func (u *testUnit) KindKey() string                           { return testUnitType }
+func (u *testUnit) CodeDir() string                           { return "" }
+func (u *testUnit) UpdateProjectRuntimeData(p *Project) error { return nil }
+func (u *testUnit) WasApplied() bool                          { return false }
+func (u *testUnit) ForceApply() bool                          { return false }
+func (u *testUnit) Mux() *sync.Mutex                          { return &u.mux }
+func (u *testUnit) IsTainted() bool                           { return u.tainted }
+func (u *testUnit) SetTainted(newValue bool, err error)       { u.tainted = newValue }
+func (u *testUnit) SetExecStatus(status ExecutionStatus)      { u.execStatus = status }
+func (u *testUnit) GetExecStatus() ExecutionStatus            { return u.execStatus }
+func (u *testUnit) ExecError() error                          { return u.execErr }
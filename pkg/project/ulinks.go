@@ -15,6 +15,8 @@ type ULinkT struct {
 	TargetStackName string      `json:"target_stack_name"`
 	OutputName      string      `json:"output_name"`
 	OutputData      interface{} `json:"output_data"`
+	// Sensitive the output data is masked in the cdev output.
+	Sensitive bool `json:"sensitive,omitempty"`
 }
 
 // UnitLinksT describe a set of links (dependencies) betwen units inside project.
@@ -38,7 +40,7 @@ func (u *ULinkT) InitUnitPtr(p *Project) (err error) {
 	modKey := fmt.Sprintf("%s.%s", u.TargetStackName, u.TargetUnitName)
 	depUnit, exists := p.Units[modKey]
 	if !exists {
-		return fmt.Errorf("link unit does not exists '%s'", modKey)
+		return p.UnresolvedLink(fmt.Errorf("link unit does not exists '%s'", modKey))
 	}
 	u.Unit = depUnit
 	return
@@ -118,6 +120,7 @@ func (o *UnitLinksT) JoinWithDataReplace(source *UnitLinksT) error {
 		if targetLink != nil {
 			if targetLink.OutputData == nil {
 				targetLink.OutputData = link.OutputData
+				targetLink.Sensitive = link.Sensitive
 			}
 		} else {
 			_, err := o.Set(link)
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -16,10 +17,10 @@ type Unit interface {
 	Prepare() error // Prepare scan all markers in unit, and build project unit links, and unit dependencies.
 	Dependencies() *UnitLinksT
 	Build() error
-	Init() error
-	Apply() error
-	Plan() error
-	Destroy() error
+	Init(ctx context.Context) error
+	Apply(ctx context.Context) error
+	Plan(ctx context.Context) error
+	Destroy(ctx context.Context) error
 	Key() string
 	GetState() Unit
 	GetDiffData() interface{}
@@ -0,0 +1,216 @@
+package project
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+// validObjectKinds the kinds of the objects in the project manifests.
+var validObjectKinds = map[string]bool{
+	stackObjKindKey:   true,
+	backendObjKindKey: true,
+	"Infrastructure":  true,
+}
+
+// Validate loads the project manifests, stack templates and units without the state and backends access
+// and without running units. It returns all found problems: object and unit schema errors, unresolved
+// template keys, references to not existing units and dependency loops.
+func Validate() (*Project, []string) {
+	p, err := LoadProjectBase()
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	p.offline = true
+	findings := p.validateObjects()
+	if len(p.Stacks) == 0 {
+		return p, findings
+	}
+	p.OwnState = p.NewEmptyState()
+	return p, append(findings, p.validateUnits()...)
+}
+
+// validateObjects reads the project objects, backends and stacks.
+func (p *Project) validateObjects() (findings []string) {
+	for _, filename := range p.sortedObjectsFiles() {
+		if p.fileIsSecret(filename) {
+			continue
+		}
+		rel, _ := filepath.Rel(config.Global.WorkingDir, filename)
+		templatedConf, isWarn, err := p.TemplateTry(p.objectsFiles[filename], filename)
+		if err != nil {
+			if !isWarn {
+				findings = append(findings, fmt.Sprintf("%v: render template: %v", rel, err.Error()))
+				continue
+			}
+			findings = append(findings, fmt.Sprintf("%v: unresolved template key: %v", rel, err.Error()))
+		}
+		if err = p.readObjects(templatedConf, filename); err != nil {
+			findings = append(findings, fmt.Sprintf("%v: %v", rel, err.Error()))
+		}
+	}
+	kinds := []string{}
+	for kind := range p.objects {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		for _, obj := range p.objects[kind] {
+			rel, _ := filepath.Rel(config.Global.WorkingDir, obj.filename)
+			switch {
+			case !validObjectKinds[kind]:
+				findings = append(findings, fmt.Sprintf("%v: unknown object kind '%v'", rel, kind))
+			case kind == backendObjKindKey:
+				if err := p.readBackendObj(obj); err != nil {
+					findings = append(findings, fmt.Sprintf("%v: reading backend: %v", rel, err.Error()))
+				}
+			}
+		}
+	}
+	if err := addDefaultBackend(p); err != nil {
+		findings = append(findings, err.Error())
+	}
+	if _, exists := p.Backends[p.StateBackendName]; !exists {
+		findings = append(findings, fmt.Sprintf("project config: state backend '%v' not found", p.StateBackendName))
+	}
+	stacks := []ObjectData{}
+	stacks = append(stacks, p.objects[stackObjKindKey]...)
+	stacks = append(stacks, p.objects["Infrastructure"]...)
+	if len(stacks) == 0 {
+		return append(findings, "no stacks found, at least one needed")
+	}
+	if err := p.checkVariablesOverrides(stacks); err != nil {
+		findings = append(findings, err.Error())
+	}
+	for _, stack := range stacks {
+		rel, _ := filepath.Rel(config.Global.WorkingDir, stack.filename)
+		if err := p.readStackObj(stack); err != nil {
+			prefix := fmt.Sprintf("stack '%v'", stack.data["name"])
+			if strings.HasPrefix(err.Error(), prefix) {
+				findings = append(findings, fmt.Sprintf("%v: %v", rel, err.Error()))
+			} else {
+				findings = append(findings, fmt.Sprintf("%v: %v: %v", rel, prefix, err.Error()))
+			}
+		}
+	}
+	for _, name := range p.sortedStackNames() {
+		for _, key := range p.Stacks[name].unresolvedKeys {
+			findings = append(findings, fmt.Sprintf("stack '%v': %v", name, key))
+		}
+	}
+	return findings
+}
+
+// validateUnits reads the units of the loaded stacks, resolves the links between them and checks
+// the dependency loops.
+func (p *Project) validateUnits() (findings []string) {
+	for _, name := range p.sortedStackNames() {
+		stack := p.Stacks[name]
+		for _, stackTmpl := range stack.Templates {
+			for _, unitData := range stackTmpl.Units {
+				unit, err := NewUnit(unitData, stack)
+				if err != nil {
+					findings = append(findings, fmt.Sprintf("stack '%v', unit '%v': %v", name, unitData["name"], err.Error()))
+					continue
+				}
+				if _, exists := p.Units[unit.Key()]; exists {
+					findings = append(findings, fmt.Sprintf("stack '%v': duplicate unit name: %v", name, unit.Name()))
+					continue
+				}
+				p.Units[unit.Key()] = unit
+			}
+		}
+	}
+	keys := make([]string, 0, len(p.Units))
+	for key := range p.Units {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p.unresolvedLinks = nil
+		if err := p.Units[key].Prepare(); err != nil {
+			findings = append(findings, fmt.Sprintf("unit '%v': %v", key, err.Error()))
+		}
+		sort.Strings(p.unresolvedLinks)
+		for _, link := range p.unresolvedLinks {
+			findings = append(findings, fmt.Sprintf("unit '%v': %v", key, link))
+		}
+	}
+	if err := checkUnitDependencies(p); err != nil {
+		findings = append(findings, err.Error())
+	}
+	return findings
+}
+
+// UnresolvedLink returns the error of the reference to not existing unit. During the validation the
+// error is collected and nil is returned, so the unit scanners continue and all references are reported.
+func (p *Project) UnresolvedLink(err error) error {
+	if !p.offline {
+		return err
+	}
+	p.unresolvedLinks = append(p.unresolvedLinks, err.Error())
+	return nil
+}
+
+func (p *Project) sortedStackNames() []string {
+	names := make([]string, 0, len(p.Stacks))
+	for name := range p.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// offlineBackend replaces the project backends during validation, so the backends are never accessed.
+type offlineBackend struct {
+	name     string
+	provider string
+}
+
+func (b *offlineBackend) Name() string {
+	return b.name
+}
+
+func (b *offlineBackend) Provider() string {
+	return b.provider
+}
+
+func (b *offlineBackend) GetBackendHCL(string, string) (*hclwrite.File, error) {
+	return nil, b.errOffline()
+}
+
+func (b *offlineBackend) GetBackendBytes(string, string) ([]byte, error) {
+	return nil, b.errOffline()
+}
+
+func (b *offlineBackend) GetRemoteStateHCL(string, string) ([]byte, error) {
+	return nil, b.errOffline()
+}
+
+func (b *offlineBackend) LockState(string) error {
+	return b.errOffline()
+}
+
+func (b *offlineBackend) ReadLock() (string, bool, error) {
+	return "", false, b.errOffline()
+}
+
+func (b *offlineBackend) UnlockState() error {
+	return b.errOffline()
+}
+
+func (b *offlineBackend) WriteState(string) error {
+	return b.errOffline()
+}
+
+func (b *offlineBackend) ReadState() (string, error) {
+	return "", b.errOffline()
+}
+
+func (b *offlineBackend) errOffline() error {
+	return fmt.Errorf("backend '%v': not available in validation", b.name)
+}
@@ -0,0 +1,151 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+)
+
+type failingBackendFactory struct{}
+
+func (f *failingBackendFactory) New([]byte, string, *Project) (Backend, error) {
+	return nil, fmt.Errorf("backend should not be created during validation")
+}
+
+// specCheckBackendFactory checks the spec, but must not create the backend.
+type specCheckBackendFactory struct {
+	failingBackendFactory
+}
+
+func (f *specCheckBackendFactory) CheckSpec(spec []byte) error {
+	return utils.UnmarshalStrict(spec, &struct {
+		Bucket string `yaml:"bucket"`
+	}{})
+}
+
+func TestValidateObjects(t *testing.T) {
+	wd := config.Global.WorkingDir
+	defer func() { config.Global.WorkingDir = wd }()
+	config.Global.WorkingDir = "/prj"
+	for _, provider := range []string{"local", "validate-test"} {
+		if _, exists := BackendsFactories[provider]; !exists {
+			BackendsFactories[provider] = &failingBackendFactory{}
+			defer delete(BackendsFactories, provider)
+		}
+	}
+	BackendsFactories["validate-spec-test"] = &specCheckBackendFactory{}
+	defer delete(BackendsFactories, "validate-spec-test")
+
+	p := &Project{
+		offline:          true,
+		StateBackendName: "remote",
+		configData:       map[string]interface{}{},
+		objects:          map[string][]ObjectData{},
+		Backends:         map[string]Backend{},
+		Stacks:           map[string]*Stack{},
+		objectsFiles: map[string][]byte{
+			"/prj/backend.yaml": []byte("name: bk\nkind: Backend\nprovider: validate-test\nspec: {}\n---\nname: w\nkind: Widget\n"),
+			"/prj/broken.yaml":  []byte("name: bk2\nkind: Backend\nprovider: unknown\nspec: {}\n"),
+			"/prj/keys.yaml":    []byte("name: bk3\nkind: Backend\nprovider: validate-test\nspec:\n  bucket: {{ .project.bucket }}\n"),
+			"/prj/spec.yaml":    []byte("name: bk4\nkind: Backend\nprovider: validate-spec-test\nspec:\n  bucket: b\n  buckett: b\n"),
+		},
+	}
+	findings := p.validateObjects()
+	expected := []string{
+		"keys.yaml: unresolved template key",
+		"broken.yaml: reading backend: 'bk2': provider does not found: unknown",
+		"spec.yaml: reading backend: 'bk4': yaml: unmarshal errors:\n  line 2: field buckett not found",
+		"backend.yaml: unknown object kind 'Widget'",
+		"project config: state backend 'remote' not found",
+		"no stacks found",
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %v findings, got %v:\n%v", len(expected), len(findings), strings.Join(findings, "\n"))
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(findings[i], e) {
+			t.Errorf("finding %v: expected '%v', got '%v'", i, e, findings[i])
+		}
+	}
+	if bk, ok := p.Backends["bk"]; !ok || bk.Provider() != "validate-test" {
+		t.Errorf("offline backend was not added: %v", p.Backends)
+	}
+	if _, err := p.Backends["bk"].ReadState(); err == nil {
+		t.Error("expected error from the offline backend")
+	}
+}
+
+func TestValidateUnits(t *testing.T) {
+	p := &Project{
+		offline:   true,
+		Units:     map[string]Unit{},
+		Stacks:    map[string]*Stack{},
+		UnitLinks: &UnitLinksT{},
+	}
+	outputMarker := func(stackName, unitName string) string {
+		marker, err := p.UnitLinks.Set(&ULinkT{LinkType: OutputLinkType, TargetStackName: stackName, TargetUnitName: unitName, OutputName: "val"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return marker
+	}
+	unit := func(name string, deps []interface{}, data map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "type": testUnitType, "depends_on": deps, "data": data}
+	}
+	p.Stacks["infra"] = &Stack{Name: "infra", ProjectPtr: p, Templates: []stackTemplate{{Units: []map[string]interface{}{
+		unit("a", []interface{}{"this.nope", "this.b"}, map[string]interface{}{
+			"ghost": outputMarker("this", "ghost"),
+			"other": outputMarker("other", "base"),
+			"ok":    outputMarker("this", "b"),
+		}),
+		unit("b", []interface{}{"this.c"}, nil),
+		unit("c", []interface{}{"this.b"}, nil),
+		unit("c", nil, nil),
+		{"name": "d", "type": "unknown"},
+	}}}}
+
+	findings := p.validateUnits()
+	expected := []string{
+		"stack 'infra': duplicate unit name: c",
+		"stack 'infra', unit 'd': new unit: bad unit type",
+		"unit 'infra.a': depend unit does not exists. Src: 'infra.a', depend: 'infra.ghost'",
+		"unit 'infra.a': depend unit does not exists. Src: 'infra.a', depend: 'other.base'",
+		"unit 'infra.a': link unit does not exists 'infra.nope'",
+		"unresolved dependency in unit infra.",
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %v findings, got %v:\n%v", len(expected), len(findings), strings.Join(findings, "\n"))
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(findings[i], e) {
+			t.Errorf("finding %v: expected '%v', got '%v'", i, e, findings[i])
+		}
+	}
+	if !strings.Contains(findings[5], "infra.b -> infra.c -> infra.b") && !strings.Contains(findings[5], "infra.c -> infra.b -> infra.c") {
+		t.Errorf("expected the loop between b and c, got '%v'", findings[5])
+	}
+	// The link to not existing unit stays unresolved and is skipped by the loops check.
+	unresolved := 0
+	for _, dep := range p.Units["infra.a"].Dependencies().Slice() {
+		if dep.Unit == nil {
+			unresolved++
+		}
+	}
+	if unresolved != 1 {
+		t.Errorf("expected 1 unresolved dependency of unit a, got %v", unresolved)
+	}
+}
+
+func TestUnresolvedLinkOnline(t *testing.T) {
+	p := &Project{Units: map[string]Unit{}}
+	link := &ULinkT{TargetStackName: "infra", TargetUnitName: "nope", LinkType: CustomLinkType}
+	if err := link.InitUnitPtr(p); err == nil {
+		t.Error("expected error for the link to not existing unit")
+	}
+	if len(p.unresolvedLinks) != 0 {
+		t.Errorf("unexpected collected links: %v", p.unresolvedLinks)
+	}
+}
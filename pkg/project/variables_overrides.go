@@ -0,0 +1,111 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"github.com/shalb/cluster.dev/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// projectOverridesKey the key of the project variables in the variables overrides.
+const projectOverridesKey = "project"
+
+// readVariablesOverrides reads the variables overrides from --var-file and --var options. The files are
+// applied in order, the --var options override the files. When the saved plan is applied, the overrides
+// are taken from the plan file.
+func (p *Project) readVariablesOverrides() error {
+	p.variablesOverrides = map[string]interface{}{}
+	if config.Global.VarsData != "" {
+		data := []byte(config.Global.VarsData)
+		if err := yaml.Unmarshal(data, &p.variablesOverrides); err != nil {
+			return fmt.Errorf("read variables overrides from plan: %w", utils.ResolveYamlError(data, err))
+		}
+		return nil
+	}
+	for _, fileName := range config.Global.VarFiles {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return fmt.Errorf("read variables file: %w", err)
+		}
+		overrides := map[string]interface{}{}
+		err = yaml.Unmarshal(data, &overrides)
+		if err != nil {
+			return fmt.Errorf("read variables file '%v': %w", fileName, utils.ResolveYamlError(data, err))
+		}
+		for name, vars := range overrides {
+			if _, ok := vars.(map[string]interface{}); !ok {
+				return fmt.Errorf("read variables file '%v': '%v' should contain a map of variables", fileName, name)
+			}
+		}
+		utils.DeepMergeMaps(p.variablesOverrides, overrides)
+	}
+	for _, v := range config.Global.Vars {
+		key, value, found := strings.Cut(v, "=")
+		path := strings.Split(key, ".")
+		if !found || len(path) < 2 || slices.Contains(path, "") {
+			return fmt.Errorf("parse --var '%v': expected format '<stack>.<key>=<value>'", v)
+		}
+		override := map[string]interface{}{path[len(path)-1]: parseVarValue(value)}
+		for i := len(path) - 2; i >= 0; i-- {
+			override = map[string]interface{}{path[i]: override}
+		}
+		utils.DeepMergeMaps(p.variablesOverrides, override)
+	}
+	return nil
+}
+
+// parseVarValue parses the --var value as yaml, so numbers, bools, lists and maps can be set. Scalars
+// which change their text after parsing (like version '1.10') are kept as strings.
+func parseVarValue(raw string) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+		return raw
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}, string:
+		return value
+	}
+	if fmt.Sprint(value) != raw {
+		return raw
+	}
+	return value
+}
+
+// overrideVariables merges the variables overrides of the stack or the project into variables.
+func (p *Project) overrideVariables(name string, variables map[string]interface{}) {
+	overrides, exists := p.variablesOverrides[name].(map[string]interface{})
+	if !exists {
+		return
+	}
+	utils.DeepMergeMaps(variables, overrides)
+}
+
+// checkVariablesOverrides checks that all overridden stacks exist. The stack named as the project
+// overrides key is ambiguous and can't be used with the overrides.
+func (p *Project) checkVariablesOverrides(stacks []ObjectData) error {
+	names := map[string]bool{projectOverridesKey: true}
+	for _, stack := range stacks {
+		if name, ok := stack.data["name"].(string); ok {
+			if name == projectOverridesKey && len(p.variablesOverrides) > 0 {
+				return fmt.Errorf("variables overrides: the key '%v' is reserved for the project variables, rename the stack '%v' to use the overrides", projectOverridesKey, name)
+			}
+			names[name] = true
+		}
+	}
+	unknown := []string{}
+	for name := range p.variablesOverrides {
+		if !names[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("variables overrides: stacks not found: %v", strings.Join(unknown, ", "))
+	}
+	return nil
+}
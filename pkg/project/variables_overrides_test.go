@@ -0,0 +1,102 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+)
+
+func TestParseVarValue(t *testing.T) {
+	tests := map[string]interface{}{
+		"v1.2.0":  "v1.2.0",
+		"3":       3,
+		"true":    true,
+		"1.10":    "1.10",
+		"007":     "007",
+		`"3"`:     "3",
+		"[a, b]":  []interface{}{"a", "b"},
+		"":        "",
+		"a=b":     "a=b",
+		"{a: 1}":  map[string]interface{}{"a": 1},
+		"2.5":     2.5,
+		"unknown": "unknown",
+	}
+	for raw, expected := range tests {
+		if res := parseVarValue(raw); !reflect.DeepEqual(res, expected) {
+			t.Errorf("parseVarValue(%q) = %#v, expected %#v", raw, res, expected)
+		}
+	}
+}
+
+func TestReadVariablesOverrides(t *testing.T) {
+	varFile := filepath.Join(t.TempDir(), "overrides.yaml")
+	err := os.WriteFile(varFile, []byte("infra:\n  image:\n    tag: v1\n    repo: app\nproject:\n  region: eu-west-1\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(vars, files []string) {
+		config.Global.Vars, config.Global.VarFiles = vars, files
+	}(config.Global.Vars, config.Global.VarFiles)
+	config.Global.VarFiles = []string{varFile}
+	config.Global.Vars = []string{"infra.image.tag=v2", "infra.replicas=3"}
+
+	p := &Project{}
+	if err := p.readVariablesOverrides(); err != nil {
+		t.Fatal(err)
+	}
+	variables := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "v0", "pull": "always"},
+		"replicas": 1,
+	}
+	p.overrideVariables("infra", variables)
+	expected := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "v2", "repo": "app", "pull": "always"},
+		"replicas": 3,
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("unexpected variables: %v", variables)
+	}
+	if err := p.checkVariablesOverrides([]ObjectData{{data: map[string]interface{}{"name": "infra"}}}); err != nil {
+		t.Errorf("unexpected overrides check error: %v", err)
+	}
+	if err := p.checkVariablesOverrides(nil); err == nil {
+		t.Error("expected unknown stack error")
+	}
+	projectStack := []ObjectData{{data: map[string]interface{}{"name": "infra"}}, {data: map[string]interface{}{"name": "project"}}}
+	if err := p.checkVariablesOverrides(projectStack); err == nil {
+		t.Error("expected reserved stack name error")
+	}
+	if err := (&Project{}).checkVariablesOverrides(projectStack); err != nil {
+		t.Errorf("the stack named 'project' should be allowed without overrides: %v", err)
+	}
+
+	config.Global.VarFiles = nil
+	for _, v := range []string{"infra", "infra=1", "infra..a=1"} {
+		config.Global.Vars = []string{v}
+		if err := p.readVariablesOverrides(); err == nil {
+			t.Errorf("expected parse error for --var '%v'", v)
+		}
+	}
+}
+
+func TestOverrideVariablesCopy(t *testing.T) {
+	p := &Project{variablesOverrides: map[string]interface{}{
+		"infra": map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}, "zones": []interface{}{"a"}},
+	}}
+	first := map[string]interface{}{}
+	second := map[string]interface{}{}
+	p.overrideVariables("infra", first)
+	p.overrideVariables("infra", second)
+	first["image"].(map[string]interface{})["tag"] = "changed"
+	first["zones"].([]interface{})[0] = "changed"
+	expected := map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}, "zones": []interface{}{"a"}}
+	if !reflect.DeepEqual(second, expected) {
+		t.Errorf("variables share the overrides data: %v", second)
+	}
+	if !reflect.DeepEqual(p.variablesOverrides["infra"], expected) {
+		t.Errorf("overrides were changed: %v", p.variablesOverrides["infra"])
+	}
+}
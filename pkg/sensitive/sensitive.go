@@ -0,0 +1,177 @@
+// Package sensitive keeps the list of the sensitive values (secrets and sensitive outputs) and masks them in the cdev output.
+package sensitive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the sensitive values.
+const Mask = "***"
+
+// minLength values shorter than this are not masked, otherwise common strings like "true" or "1" would be hidden everywhere.
+const minLength = 4
+
+// minLineLength lines of the multiline value shorter than this are not masked separately, otherwise common lines
+// like "}" or "type: Opaque" would be hidden everywhere.
+const minLineLength = 16
+
+// commonLineRe matches the lines without secret data: JSON punctuation, keys of objects and lists, PEM armor lines.
+var commonLineRe = regexp.MustCompile(`^(("[^"]*"\s*:)?\s*[\[\]{},]*|-----[A-Z ]+-----)$`)
+
+var registry = struct {
+	mux      sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+	disabled bool
+}{
+	values: map[string]bool{},
+}
+
+// Add adds the values to the sensitive values list.
+func Add(values ...string) {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	changed := false
+	for _, value := range values {
+		for _, v := range variants(value) {
+			if len(v) < minLength || registry.values[v] {
+				continue
+			}
+			registry.values[v] = true
+			changed = true
+		}
+	}
+	if changed {
+		updateReplacer()
+	}
+}
+
+// AddData adds all scalar values of the data (strings, numbers, maps and lists of them) to the sensitive values list.
+func AddData(data interface{}) {
+	switch val := data.(type) {
+	case nil:
+	case string:
+		Add(val)
+	case map[string]interface{}:
+		for _, v := range val {
+			AddData(v)
+		}
+	case []interface{}:
+		for _, v := range val {
+			AddData(v)
+		}
+	case bool:
+	default:
+		Add(fmt.Sprint(val))
+	}
+}
+
+// variants returns the value and its forms, which can appear in the output: JSON-escaped value and separate lines of
+// the multiline value. The lines of JSON documents are not split, they are common for all documents of the same shape.
+func variants(value string) []string {
+	res := []string{value}
+	for _, escapeHTML := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(escapeHTML)
+		if err := encoder.Encode(value); err == nil {
+			escaped := strings.TrimSuffix(buf.String(), "\n")
+			res = append(res, escaped[1:len(escaped)-1])
+		}
+	}
+	if !strings.Contains(value, "\n") || isJSONDocument(value) {
+		return res
+	}
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) >= minLineLength && !commonLineRe.MatchString(line) {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// isJSONDocument returns true if the value is the JSON object or list.
+func isJSONDocument(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	return (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed))
+}
+
+func updateReplacer() {
+	values := make([]string, 0, len(registry.values))
+	for v := range registry.values {
+		values = append(values, v)
+	}
+	// Longer values first, so the value which contains another value is masked entirely.
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	oldnew := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		oldnew = append(oldnew, v, Mask)
+	}
+	registry.replacer = strings.NewReplacer(oldnew...)
+}
+
+// Disable disables masking, used to show the sensitive values on the user request.
+func Disable() {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registry.disabled = true
+}
+
+// Reset clears the sensitive values list and enables masking.
+func Reset() {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registry.values = map[string]bool{}
+	registry.replacer = nil
+	registry.disabled = false
+}
+
+// IsDisabled returns true if masking is disabled.
+func IsDisabled() bool {
+	registry.mux.RLock()
+	defer registry.mux.RUnlock()
+	return registry.disabled
+}
+
+// MaskString replaces all sensitive values in the string with the mask.
+func MaskString(s string) string {
+	registry.mux.RLock()
+	defer registry.mux.RUnlock()
+	if registry.disabled || registry.replacer == nil {
+		return s
+	}
+	return registry.replacer.Replace(s)
+}
+
+// MaskData returns the copy of the data with the sensitive values masked in all strings.
+func MaskData(data interface{}) interface{} {
+	switch val := data.(type) {
+	case string:
+		return MaskString(val)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			res[k] = MaskData(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, v := range val {
+			res[i] = MaskData(v)
+		}
+		return res
+	}
+	return data
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/shalb/cluster.dev/pkg/executor"
+	"github.com/shalb/cluster.dev/pkg/project"
+)
+
+// RetrySpec describes retry policy for unit apply and destroy commands.
+type RetrySpec struct {
+	// Attempts total number of command set runs, including the first one.
+	Attempts int `yaml:"attempts" json:"attempts"`
+	// Delay before the second attempt, duration string ('10s', '1m') or number of seconds.
+	Delay string `yaml:"delay,omitempty" json:"delay,omitempty"`
+	// Backoff multiplier for delay after each failed attempt. Default 1 (constant delay).
+	Backoff float64 `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+	// OnOutputRegex if set, retry only when the command output or error matches the expression.
+	OnOutputRegex string `yaml:"on_output_regex,omitempty" json:"on_output_regex,omitempty"`
+}
+
+// Validate checks retry policy configuration.
+func (r *RetrySpec) Validate() error {
+	if r.Attempts < 1 {
+		return fmt.Errorf("retry: 'attempts' should be greater than 0")
+	}
+	if _, err := r.delay(); err != nil {
+		return err
+	}
+	if r.Backoff != 0 && r.Backoff < 1 {
+		return fmt.Errorf("retry: 'backoff' should not be less than 1")
+	}
+	if _, err := r.outputRegex(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *RetrySpec) delay() (time.Duration, error) {
+	res, err := parseDuration(r.Delay)
+	if err != nil {
+		return 0, fmt.Errorf("retry: bad 'delay' value '%v': %w", r.Delay, err)
+	}
+	return res, nil
+}
+
+func (r *RetrySpec) outputRegex() (*regexp.Regexp, error) {
+	if r.OnOutputRegex == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(r.OnOutputRegex)
+	if err != nil {
+		return nil, fmt.Errorf("retry: bad 'on_output_regex' value: %w", err)
+	}
+	return re, nil
+}
+
+// retryAfter waits for the delay before the next attempt, replaced in tests.
+var retryAfter = time.After
+
+// runCommandsWithRetry runs commands set and re-runs it on failure according to the unit retry policy.
+func (u *Unit) runCommandsWithRetry(ctx context.Context, commandsCnf OperationConfig, name string) ([]byte, error) {
+	if u.Retry == nil {
+		return u.runCommands(ctx, commandsCnf, name)
+	}
+	err := u.Retry.Validate()
+	if err != nil {
+		return nil, err
+	}
+	// The running attempt is finished after the first interrupt, but the new ones are not started.
+	interruptCtx := project.InterruptContext(ctx)
+	return u.Retry.run(ctx, interruptCtx, executor.LogPrefix(u.logLabels(name)), func() ([]byte, error) {
+		return u.runCommands(ctx, commandsCnf, name)
+	})
+}
+
+// run calls attemptFunc and calls it again on failure according to the retry policy. The new attempts
+// are not started when interruptCtx is done. The policy should be validated before.
+func (r *RetrySpec) run(ctx, interruptCtx context.Context, logPrefix string, attemptFunc func() ([]byte, error)) ([]byte, error) {
+	delay, _ := r.delay()
+	re, _ := r.outputRegex()
+	backoff := r.Backoff
+	if backoff == 0 {
+		backoff = 1
+	}
+	for attempt := 1; ; attempt++ {
+		otp, err := attemptFunc()
+		if err == nil {
+			if attempt > 1 {
+				log.Infof("%s attempt %d/%d succeeded", logPrefix, attempt, r.Attempts)
+			}
+			return otp, nil
+		}
+		if attempt >= r.Attempts {
+			if r.Attempts > 1 {
+				log.Errorf("%s attempt %d/%d failed, no attempts left", logPrefix, attempt, r.Attempts)
+			}
+			return otp, err
+		}
+		if re != nil && !re.MatchString(string(otp)) && !re.MatchString(err.Error()) {
+			log.Warnf("%s attempt %d/%d failed, output does not match '%v', not retrying", logPrefix, attempt, r.Attempts, r.OnOutputRegex)
+			return otp, err
+		}
+		if ctx.Err() != nil {
+			return otp, err
+		}
+		if interruptCtx.Err() != nil {
+			log.Warnf("%s attempt %d/%d failed, interrupted, not retrying", logPrefix, attempt, r.Attempts)
+			return otp, err
+		}
+		log.Warnf("%s attempt %d/%d failed, retrying in %v: %v", logPrefix, attempt, r.Attempts, delay, err)
+		select {
+		case <-retryAfter(delay):
+		case <-ctx.Done():
+			return otp, fmt.Errorf("interrupted: %w", ctx.Err())
+		case <-interruptCtx.Done():
+			return otp, fmt.Errorf("interrupted: %w", err)
+		}
+		delay = time.Duration(float64(delay) * backoff)
+	}
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordRetryDelays replaces the retry wait: the delays are recorded, the wait is not done.
+func recordRetryDelays(t *testing.T) *[]time.Duration {
+	delays := []time.Duration{}
+	after := retryAfter
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { retryAfter = after })
+	return &delays
+}
+
+// failingAttempts returns the attempt function, which fails 'fail' times with the output and then succeeds.
+func failingAttempts(fail int, output string) (func() ([]byte, error), *int) {
+	calls := 0
+	return func() ([]byte, error) {
+		calls++
+		if calls <= fail {
+			return []byte(output), fmt.Errorf("attempt %d failed", calls)
+		}
+		return []byte("ok"), nil
+	}, &calls
+}
+
+func TestRunCommandsWithRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetrySpec
+		fail    int
+		output  string
+		calls   int
+		delays  []time.Duration
+		wantErr bool
+	}{
+		{
+			name:   "success after retries",
+			retry:  RetrySpec{Attempts: 3, Delay: "10s"},
+			fail:   2,
+			calls:  3,
+			delays: []time.Duration{10 * time.Second, 10 * time.Second},
+		},
+		{
+			name:    "no attempts left",
+			retry:   RetrySpec{Attempts: 3, Delay: "10s"},
+			fail:    5,
+			calls:   3,
+			delays:  []time.Duration{10 * time.Second, 10 * time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "single attempt",
+			retry:   RetrySpec{Attempts: 1},
+			fail:    1,
+			calls:   1,
+			delays:  []time.Duration{},
+			wantErr: true,
+		},
+		{
+			name:    "backoff",
+			retry:   RetrySpec{Attempts: 4, Delay: "1", Backoff: 2},
+			fail:    4,
+			calls:   4,
+			delays:  []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+			wantErr: true,
+		},
+		{
+			name:   "output matches",
+			retry:  RetrySpec{Attempts: 2, OnOutputRegex: "i/o timeout"},
+			fail:   1,
+			output: "dial tcp: i/o timeout",
+			calls:  2,
+			delays: []time.Duration{0},
+		},
+		{
+			name:    "output does not match",
+			retry:   RetrySpec{Attempts: 3, OnOutputRegex: "i/o timeout"},
+			fail:    1,
+			output:  "access denied",
+			calls:   1,
+			delays:  []time.Duration{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.retry.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			delays := recordRetryDelays(t)
+			attempt, calls := failingAttempts(tt.fail, tt.output)
+			ctx := context.Background()
+			otp, err := tt.retry.run(ctx, ctx, "[test]", attempt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil && string(otp) != "ok" {
+				t.Errorf("unexpected output: %s", otp)
+			}
+			if *calls != tt.calls {
+				t.Errorf("expected %v attempts, got %v", tt.calls, *calls)
+			}
+			if !reflect.DeepEqual(*delays, tt.delays) {
+				t.Errorf("expected delays %v, got %v", tt.delays, *delays)
+			}
+		})
+	}
+}
+
+func TestRunCommandsWithRetryInterrupt(t *testing.T) {
+	retry := RetrySpec{Attempts: 3, Delay: "1h"}
+
+	// The first interrupt: the running attempt is finished, the next one is not started.
+	interruptCtx, interrupt := context.WithCancel(context.Background())
+	attempt, calls := failingAttempts(3, "")
+	_, err := retry.run(context.Background(), interruptCtx, "[test]", func() ([]byte, error) {
+		interrupt()
+		return attempt()
+	})
+	if err == nil || *calls != 1 {
+		t.Errorf("expected one failed attempt, got %v attempts, error %v", *calls, err)
+	}
+
+	// The interrupt during the delay.
+	interruptCtx, interrupt = context.WithCancel(context.Background())
+	defer interrupt()
+	after := retryAfter
+	defer func() { retryAfter = after }()
+	retryAfter = func(time.Duration) <-chan time.Time {
+		interrupt()
+		return make(chan time.Time)
+	}
+	attempt, calls = failingAttempts(3, "")
+	_, err = retry.run(context.Background(), interruptCtx, "[test]", attempt)
+	if err == nil || *calls != 1 {
+		t.Errorf("expected one failed attempt, got %v attempts, error %v", *calls, err)
+	}
+
+	// The units context is done (the second interrupt).
+	unitCtx, kill := context.WithCancel(context.Background())
+	kill()
+	attempt, calls = failingAttempts(3, "")
+	_, err = retry.run(unitCtx, unitCtx, "[test]", attempt)
+	if err == nil || *calls != 1 {
+		t.Errorf("expected one failed attempt, got %v attempts, error %v", *calls, err)
+	}
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// TimeoutSpec describes unit operations timeouts. In unit YAML it can be set as a single value
+// for all operations (timeout: 30m) or separately for each operation.
+type TimeoutSpec struct {
+	Init    string `yaml:"init,omitempty" json:"init,omitempty"`
+	Plan    string `yaml:"plan,omitempty" json:"plan,omitempty"`
+	Apply   string `yaml:"apply,omitempty" json:"apply,omitempty"`
+	Destroy string `yaml:"destroy,omitempty" json:"destroy,omitempty"`
+}
+
+// UnmarshalYAML allows to set the same timeout for all operations with scalar value.
+func (t *TimeoutSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		t.Init, t.Plan, t.Apply, t.Destroy = value.Value, value.Value, value.Value, value.Value
+		return nil
+	}
+	type plain TimeoutSpec
+	return value.Decode((*plain)(t))
+}
+
+// Validate checks timeouts format.
+func (t *TimeoutSpec) Validate() error {
+	for op, val := range map[string]string{"init": t.Init, "plan": t.Plan, "apply": t.Apply, "destroy": t.Destroy} {
+		if _, err := parseDuration(val); err != nil {
+			return fmt.Errorf("timeout: bad '%v' value: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// parseDuration parses duration string ('10s', '1m') or number of seconds. Empty string means zero.
+func parseDuration(val string) (time.Duration, error) {
+	if val == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(val, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(val)
+}
+
+// operationTimeout returns timeout for the unit operation. Unit settings have priority over the global --timeout flag.
+func (u *Unit) operationTimeout(operation string) time.Duration {
+	if u.Timeout == nil {
+		return config.Global.UnitTimeout
+	}
+	var val string
+	switch operation {
+	case "init":
+		val = u.Timeout.Init
+	case "plan":
+		val = u.Timeout.Plan
+	case "apply", "retrieving outputs":
+		val = u.Timeout.Apply
+	case "destroy":
+		val = u.Timeout.Destroy
+	}
+	res, err := parseDuration(val)
+	if err != nil || res == 0 {
+		return config.Global.UnitTimeout
+	}
+	return res
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shalb/cluster.dev/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTimeoutSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected TimeoutSpec
+		valid    bool
+	}{
+		{
+			name:     "scalar",
+			yaml:     "timeout: 30m",
+			expected: TimeoutSpec{Init: "30m", Plan: "30m", Apply: "30m", Destroy: "30m"},
+			valid:    true,
+		},
+		{
+			name:     "seconds",
+			yaml:     "timeout: 90",
+			expected: TimeoutSpec{Init: "90", Plan: "90", Apply: "90", Destroy: "90"},
+			valid:    true,
+		},
+		{
+			name:     "per operation",
+			yaml:     "timeout:\n  apply: 1h\n  destroy: 20m",
+			expected: TimeoutSpec{Apply: "1h", Destroy: "20m"},
+			valid:    true,
+		},
+		{
+			name:     "invalid scalar",
+			yaml:     "timeout: soon",
+			expected: TimeoutSpec{Init: "soon", Plan: "soon", Apply: "soon", Destroy: "soon"},
+		},
+		{
+			name:     "invalid operation",
+			yaml:     "timeout:\n  plan: 5x",
+			expected: TimeoutSpec{Plan: "5x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := struct {
+				Timeout *TimeoutSpec `yaml:"timeout"`
+			}{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &spec); err != nil {
+				t.Fatal(err)
+			}
+			if *spec.Timeout != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *spec.Timeout)
+			}
+			if err := spec.Timeout.Validate(); (err == nil) != tt.valid {
+				t.Errorf("unexpected validation result: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		val      string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{val: "", expected: 0},
+		{val: "10s", expected: 10 * time.Second},
+		{val: "1h30m", expected: 90 * time.Minute},
+		{val: "90", expected: 90 * time.Second},
+		{val: "0.5", expected: 500 * time.Millisecond},
+		{val: "10 minutes", wantErr: true},
+		{val: "m", wantErr: true},
+	}
+	for _, tt := range tests {
+		res, err := parseDuration(tt.val)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("'%v': unexpected error: %v", tt.val, err)
+			continue
+		}
+		if res != tt.expected {
+			t.Errorf("'%v': expected %v, got %v", tt.val, tt.expected, res)
+		}
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { config.Global.UnitTimeout = timeout }(config.Global.UnitTimeout)
+	config.Global.UnitTimeout = time.Hour
+
+	tests := []struct {
+		name      string
+		timeout   *TimeoutSpec
+		operation string
+		expected  time.Duration
+	}{
+		{name: "global", operation: "apply", expected: time.Hour},
+		{name: "unit", timeout: &TimeoutSpec{Apply: "10m"}, operation: "apply", expected: 10 * time.Minute},
+		{name: "outputs", timeout: &TimeoutSpec{Apply: "10m"}, operation: "retrieving outputs", expected: 10 * time.Minute},
+		{name: "other operation", timeout: &TimeoutSpec{Apply: "10m"}, operation: "destroy", expected: time.Hour},
+		{name: "seconds", timeout: &TimeoutSpec{Plan: "30"}, operation: "plan", expected: 30 * time.Second},
+		{name: "invalid", timeout: &TimeoutSpec{Init: "soon"}, operation: "init", expected: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Unit{Timeout: tt.timeout}
+			if res := u.operationTimeout(tt.operation); res != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -70,6 +72,8 @@ type Unit struct {
 	BackendName      string                  `yaml:"-" json:"backend_name"`
 	SavedState       project.Unit            `yaml:"-" json:"-"`
 	DependsOn        interface{}             `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	Retry            *RetrySpec              `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Timeout          *TimeoutSpec            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	FApply           bool                    `yaml:"force_apply" json:"force_apply"`
 	lockedMux        *sync.Mutex             `yaml:"-" json:"-"`
 	Tainted          bool                    `yaml:"-" json:"tainted,omitempty"`
@@ -129,6 +133,18 @@ func (u *Unit) ReadConfig(spec map[string]interface{}, stack *project.Stack) err
 	if err != nil {
 		return fmt.Errorf("read dependencies: %w", err)
 	}
+	if u.Retry != nil {
+		err = u.Retry.Validate()
+		if err != nil {
+			return fmt.Errorf("read unit '%v': %w", u.Name(), err)
+		}
+	}
+	if u.Timeout != nil {
+		err = u.Timeout.Validate()
+		if err != nil {
+			return fmt.Errorf("read unit '%v': %w", u.Name(), err)
+		}
+	}
 	if u.WorkDir != "" {
 		u.WorkDir = filepath.Join(config.Global.WorkingDir, u.StackPtr.TemplateDir, u.WorkDir)
 		isDir, err := utils.CheckDir(u.WorkDir)
@@ -222,12 +238,12 @@ func (u *Unit) Dependencies() *project.UnitLinksT {
 }
 
 // Init runs init procedure for unit.
-func (u *Unit) Init() error {
-	_, err := u.runCommands(*u.InitConf, "init")
+func (u *Unit) Init(ctx context.Context) error {
+	_, err := u.runCommands(ctx, *u.InitConf, "init")
 	return err
 }
 
-func (u *Unit) forceApplyDependencies() (err error) {
+func (u *Unit) forceApplyDependencies(ctx context.Context) (err error) {
 	for _, dep := range findForceApplyDependencies(u) {
 		if !dep.ForceApply() {
 			continue
@@ -237,7 +253,7 @@ func (u *Unit) forceApplyDependencies() (err error) {
 		if err != nil {
 			return err
 		}
-		err = dep.Apply()
+		err = dep.Apply(ctx)
 		if err != nil {
 			return err
 		}
@@ -267,14 +283,14 @@ func findForceApplyDependenciesRecursive(u project.Unit, res *map[string]project
 }
 
 // Apply runs unit apply procedure.
-func (u *Unit) Apply() error {
+func (u *Unit) Apply(ctx context.Context) error {
 	u.Mux().Lock()
 	defer u.Mux().Unlock()
 	if u.AlreadyApplied {
 		log.Debugf("A duplicate apply detected. Unit: '%v', skip...", u.Key())
 		return nil
 	}
-	err := u.forceApplyDependencies()
+	err := u.forceApplyDependencies(ctx)
 	if err != nil {
 		return err
 	}
@@ -286,7 +302,7 @@ func (u *Unit) Apply() error {
 	if u.PostHook != nil && u.PostHook.OnApply {
 		applyCommands.Commands = append(applyCommands.Commands, "./post_hook.sh")
 	}
-	u.OutputRaw, err = u.runCommands(applyCommands, "apply")
+	u.OutputRaw, err = u.runCommandsWithRetry(ctx, applyCommands, "apply")
 	// unitIsTainted := err != nil
 
 	if err != nil {
@@ -300,7 +316,7 @@ func (u *Unit) Apply() error {
 				u.GetOutputsConf.Command,
 			},
 		}
-		u.OutputRaw, err = u.runCommands(cmdConf, "retrieving outputs")
+		u.OutputRaw, err = u.runCommands(ctx, cmdConf, "retrieving outputs")
 		if err != nil {
 			u.SetTainted(true, err)
 			return fmt.Errorf("retrieving unit '%v' outputs: %w", u.Key(), err)
@@ -336,7 +352,16 @@ func (u *Unit) Apply() error {
 // 	}
 // }
 
-func (u *Unit) runCommands(commandsCnf OperationConfig, name string) ([]byte, error) {
+// logLabels returns the labels of the unit operation log messages.
+func (u *Unit) logLabels(operation string) []string {
+	return []string{
+		u.StackName(),
+		u.Name(),
+		operation,
+	}
+}
+
+func (u *Unit) runCommands(ctx context.Context, commandsCnf OperationConfig, name string) ([]byte, error) {
 	if len(commandsCnf.Commands) == 0 {
 		log.Debugf("configuration for '%v' is empty for unit '%v'. Skip.", name, u.Key())
 		return nil, nil
@@ -351,11 +376,8 @@ func (u *Unit) runCommands(commandsCnf OperationConfig, name string) ([]byte, er
 		return nil, err
 	}
 
-	rn.LogLabels = []string{
-		u.StackName(),
-		u.Name(),
-		name,
-	}
+	rn.LogLabels = u.logLabels(name)
+	rn.Timeout = u.operationTimeout(name)
 	var errMsg []byte
 
 	var cmd string
@@ -366,7 +388,10 @@ func (u *Unit) runCommands(commandsCnf OperationConfig, name string) ([]byte, er
 			cmd += "\n"
 		}
 	}
-	otp, errMsg, err := rn.Run(cmd)
+	otp, errMsg, err := rn.RunContext(ctx, cmd)
+	if errors.Is(err, executor.ErrTimeout) {
+		return otp, fmt.Errorf("unit '%v' %v: %w", u.Key(), name, err)
+	}
 	if err != nil {
 		// log.Errorf("%v", string(errMsg))
 		return otp, fmt.Errorf("%w, error output:\n %v", err, string(errMsg))
@@ -375,7 +400,7 @@ func (u *Unit) runCommands(commandsCnf OperationConfig, name string) ([]byte, er
 }
 
 // Plan unit.
-func (u *Unit) Plan() error {
+func (u *Unit) Plan(ctx context.Context) error {
 	planCommands := OperationConfig{}
 	if u.PreHook != nil && u.PreHook.OnPlan {
 		planCommands.Commands = append(planCommands.Commands, "./pre_hook.sh")
@@ -386,19 +411,19 @@ func (u *Unit) Plan() error {
 	if u.PostHook != nil && u.PostHook.OnPlan {
 		planCommands.Commands = append(planCommands.Commands, "./post_hook.sh")
 	}
-	_, err := u.runCommands(planCommands, "plan")
+	_, err := u.runCommands(ctx, planCommands, "plan")
 	return err
 }
 
 // Destroy unit.
-func (u *Unit) Destroy() error {
+func (u *Unit) Destroy(ctx context.Context) error {
 	u.Mux().Lock()
 	defer u.Mux().Unlock()
 	if u.AlreadyDestroyed {
 		log.Debugf("A duplicate destroy detected. Unit: '%v', skip...", u.Key())
 		return nil
 	}
-	err := u.forceApplyDependencies()
+	err := u.forceApplyDependencies(ctx)
 	if err != nil {
 		return err
 	}
@@ -414,7 +439,7 @@ func (u *Unit) Destroy() error {
 	if u.PostHook != nil && u.PostHook.OnDestroy {
 		destroyCommands.Commands = append(destroyCommands.Commands, "./post_hook.sh")
 	}
-	_, err = u.runCommands(destroyCommands, "destroy")
+	_, err = u.runCommandsWithRetry(ctx, destroyCommands, "destroy")
 	if err != nil {
 		u.SetTainted(true, err)
 	} else {
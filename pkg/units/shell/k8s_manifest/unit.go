@@ -1,6 +1,7 @@
 package base
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"path/filepath"
@@ -83,7 +84,7 @@ func (u *Unit) fillShellUnit() {
 	u.GetOutputsConf = nil
 }
 
-func (u *Unit) createNamespacesIfNotExists() error {
+func (u *Unit) createNamespacesIfNotExists(ctx context.Context) error {
 	rn, err := executor.NewExecutor(u.CacheDir)
 	if err != nil {
 		log.Debug(err.Error())
@@ -96,7 +97,7 @@ func (u *Unit) createNamespacesIfNotExists() error {
 				kubeconfigOpt = fmt.Sprintf("--kubeconfig='%s'", *u.Kubeconfig)
 			}
 			cmd := fmt.Sprintf("%s %s create ns %s", kubectlBin, kubeconfigOpt, ns)
-			_, errMsg, err := rn.Run(cmd)
+			_, errMsg, err := rn.RunContext(ctx, cmd)
 			if len(errMsg) > 1 {
 				if err != nil {
 					log.Debugf("Failed attempt to create namespace (ignore) %v", string(errMsg))
@@ -178,17 +179,17 @@ func (u *Unit) ReadConfig(spec map[string]interface{}, stack *project.Stack) err
 }
 
 // Init unit.
-func (u *Unit) Init() error {
+func (u *Unit) Init(ctx context.Context) error {
 	return nil
 }
 
 // Apply unit.
-func (u *Unit) Apply() error {
-	err := u.createNamespacesIfNotExists()
+func (u *Unit) Apply(ctx context.Context) error {
+	err := u.createNamespacesIfNotExists(ctx)
 	if err != nil {
 		return err
 	}
-	err = u.Unit.Apply()
+	err = u.Unit.Apply(ctx)
 	if err != nil {
 		return err
 	}
@@ -197,13 +198,13 @@ func (u *Unit) Apply() error {
 }
 
 // Plan unit.
-func (u *Unit) Plan() error {
+func (u *Unit) Plan(ctx context.Context) error {
 	return nil
 }
 
 // Destroy unit.
-func (u *Unit) Destroy() error {
-	err := u.Unit.Destroy()
+func (u *Unit) Destroy(ctx context.Context) error {
+	err := u.Unit.Destroy(ctx)
 	if err != nil {
 		return err
 	}
@@ -6,12 +6,17 @@ import (
 
 	"github.com/apex/log"
 	"github.com/shalb/cluster.dev/pkg/project"
+	"github.com/shalb/cluster.dev/pkg/sensitive"
 	"github.com/shalb/cluster.dev/pkg/utils"
 )
 
 // TerraformJSONParser parse in (expected JSON string)
 // and stores it in the value pointed to by out.
 func TerraformJSONParser(in string, out *project.UnitLinksT) error {
+	// Sensitive outputs are masked in the cdev output, even if they are not used by other units.
+	if sensitiveOutputs, err := utils.TerraformJSONSensitiveOutputs(in); err == nil {
+		sensitive.AddData(sensitiveOutputs)
+	}
 	if out == nil || out.IsEmpty() {
 		log.Debugf("TerraformJSONParser: unit has no expected outputs, ignore")
 		return nil
@@ -28,6 +33,7 @@ func TerraformJSONParser(in string, out *project.UnitLinksT) error {
 	}
 
 	outTmp := make(map[string]string)
+	sensitiveOutputs := make(map[string]bool)
 	for key, val := range tfOutputData {
 		tp := reflect.ValueOf(val.Type)
 		if tp.Kind() != reflect.String || val.Type.(string) != "string" {
@@ -35,6 +41,7 @@ func TerraformJSONParser(in string, out *project.UnitLinksT) error {
 		}
 		strValue := fmt.Sprintf("%v", val.Value)
 		outTmp[key] = strValue
+		sensitiveOutputs[key] = val.Sensitive
 	}
 	for _, expOutput := range out.Map() {
 		data, exists := outTmp[expOutput.OutputName]
@@ -42,6 +49,7 @@ func TerraformJSONParser(in string, out *project.UnitLinksT) error {
 			return fmt.Errorf("parse outputs: unit has no output named '%v', expected by another unit", expOutput.OutputName)
 		}
 		expOutput.OutputData = data
+		expOutput.Sensitive = sensitiveOutputs[expOutput.OutputName]
 	}
 	return nil
 }
@@ -31,7 +31,11 @@ func (u *Unit) RemoteStatesScanner(data reflect.Value, unit project.Unit) (refle
 			modKey := fmt.Sprintf("%s.%s", stackName, link.TargetUnitName)
 			depUnit, exists := unit.Project().Units[modKey]
 			if !exists {
-				return reflect.ValueOf(nil), fmt.Errorf("Depend unit does not exists. Src: '%s.%s', depend: '%s'", unit.Stack().Name, unit.Name(), modKey)
+				err := unit.Project().UnresolvedLink(fmt.Errorf("Depend unit does not exists. Src: '%s.%s', depend: '%s'", unit.Stack().Name, unit.Name(), modKey))
+				if err != nil {
+					return reflect.ValueOf(nil), err
+				}
+				continue
 			}
 			if link.Unit == nil {
 				link.Unit = depUnit
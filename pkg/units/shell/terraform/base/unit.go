@@ -1,6 +1,8 @@
 package base
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -92,10 +94,10 @@ func (u *Unit) ReadConfig(spec map[string]interface{}, stack *project.Stack) err
 }
 
 // Init unit.
-func (u *Unit) Init() error {
+func (u *Unit) Init(ctx context.Context) error {
 	u.ProjectPtr.InitLock.Lock()
 	defer u.ProjectPtr.InitLock.Unlock()
-	err := u.Unit.Init()
+	err := u.Unit.Init(ctx)
 	if err != nil {
 		return err
 	}
@@ -104,33 +106,39 @@ func (u *Unit) Init() error {
 }
 
 // Apply unit.
-func (u *Unit) Apply() error {
+func (u *Unit) Apply(ctx context.Context) error {
 	if !u.InitDone {
-		if err := u.Init(); err != nil {
+		if err := u.Init(ctx); err != nil {
+			if errors.Is(err, executor.ErrTimeout) {
+				u.SetTainted(true, err)
+			}
 			return err
 		}
 	}
-	return u.Unit.Apply()
+	return u.Unit.Apply(ctx)
 }
 
 // Plan unit.
-func (u *Unit) Plan() error {
+func (u *Unit) Plan(ctx context.Context) error {
 	if !u.InitDone {
-		if err := u.Init(); err != nil {
+		if err := u.Init(ctx); err != nil {
 			return err
 		}
 	}
-	return u.Unit.Plan()
+	return u.Unit.Plan(ctx)
 }
 
 // Destroy unit.
-func (u *Unit) Destroy() error {
+func (u *Unit) Destroy(ctx context.Context) error {
 	if !u.InitDone {
-		if err := u.Init(); err != nil {
+		if err := u.Init(ctx); err != nil {
+			if errors.Is(err, executor.ErrTimeout) {
+				u.SetTainted(true, err)
+			}
 			return err
 		}
 	}
-	return u.Unit.Destroy()
+	return u.Unit.Destroy(ctx)
 }
 
 // Output unit.
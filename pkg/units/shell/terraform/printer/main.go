@@ -1,6 +1,7 @@
 package tfmodule
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 
@@ -126,8 +127,8 @@ func (u *Unit) Build() error {
 	return u.Unit.Build()
 }
 
-func (u *Unit) Destroy() (err error) {
-	err = u.Unit.Destroy()
+func (u *Unit) Destroy(ctx context.Context) (err error) {
+	err = u.Unit.Destroy(ctx)
 	// if u.IsTainted() {
 	// 	if u.SavedState != nil {
 	// 		u.SavedState.SetTainted(true, err)
@@ -140,8 +141,8 @@ func (u *Unit) Destroy() (err error) {
 	return
 }
 
-func (u *Unit) Apply() (err error) {
-	err = u.Unit.Apply()
+func (u *Unit) Apply(ctx context.Context) (err error) {
+	err = u.Unit.Apply(ctx)
 	// if u.IsTainted() {
 	// 	if u.SavedState != nil {
 	// 		u.SavedState.SetTainted(true)
@@ -69,6 +69,26 @@ func JSONDecode(in []byte, out interface{}) error {
 	return decoder.Decode(&out)
 }
 
+// TerraformJSONSensitiveOutputs returns the values of the sensitive outputs from the 'terraform output -json' result.
+func TerraformJSONSensitiveOutputs(in string) (map[string]interface{}, error) {
+	type tfOutputDataSpec struct {
+		Sensitive bool        `json:"sensitive"`
+		Value     interface{} `json:"value"`
+	}
+	tfOutputData := make(map[string]tfOutputDataSpec)
+	err := JSONDecode([]byte(in), &tfOutputData)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]interface{})
+	for key, val := range tfOutputData {
+		if val.Sensitive {
+			res[key] = val.Value
+		}
+	}
+	return res, nil
+}
+
 func MergeMaps(mOne, mTwo map[string]interface{}) (res map[string]interface{}) {
 	for k, v := range mOne {
 		res[k] = v
@@ -79,6 +99,39 @@ func MergeMaps(mOne, mTwo map[string]interface{}) (res map[string]interface{}) {
 	return
 }
 
+// DeepMergeMaps merges src into dst recursively: nested maps are merged, other values from src replace the values in dst.
+// The maps and lists from src are copied, so dst does not share them with src.
+func DeepMergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			DeepMergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[k] = deepCopyValue(v)
+	}
+}
+
+// deepCopyValue returns the copy of the nested maps and lists, other values are returned as is.
+func deepCopyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			res[k] = deepCopyValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, item := range val {
+			res[i] = deepCopyValue(item)
+		}
+		return res
+	}
+	return v
+}
+
 // TerraformJSONOutputParse parse data from terraform output --json command to map and line-to-line string
 func TerraformJSONOutputParse(in string) (out map[string]string, stringOut string, err error) {
 	type tfOutputDataSpec struct {
@@ -31,3 +31,14 @@ func ReadYAML(objData []byte) (res map[string]interface{}, err error) {
 	err = ResolveYamlError(objData, err)
 	return
 }
+
+// UnmarshalStrict same as yaml.Unmarshal, but fails on the fields which don't exist in out.
+func UnmarshalStrict(data []byte, out interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(out)
+	if err != nil && err.Error() != "EOF" {
+		return ResolveYamlError(data, err)
+	}
+	return nil
+}